@@ -0,0 +1,85 @@
+package dimen
+
+import "math"
+
+// Largest and smallest dimensions.
+const (
+	MaxDimen Dimen = math.MaxInt32
+	MinDimen Dimen = math.MinInt32
+)
+
+// MulRatio multiplies a dimension by the ratio n/m, rounding to the nearest
+// scaled point. Results outside the range of dimensions are clamped to
+// MaxDimen or MinDimen; the same holds for a ratio with m = 0.
+func (d Dimen) MulRatio(n, m int64) Dimen {
+	if d == 0 || n == 0 {
+		return 0
+	}
+	if m == 0 {
+		if (d < 0) != (n < 0) {
+			return MinDimen
+		}
+		return MaxDimen
+	}
+	if m < 0 {
+		n, m = -n, -m
+	}
+	// d has at most 32 bits, so d·n overflows int64 only for |n| ≥ 2^31
+	// and we fall back to float arithmetic.
+	if n > math.MaxInt32 || n < math.MinInt32 {
+		return saturate(math.Round(float64(d) * float64(n) / float64(m)))
+	}
+	p := int64(d) * n
+	q, r := p/m, p%m
+	if abs64(r) >= m-abs64(r) { // round half away from zero
+		if p < 0 {
+			q--
+		} else {
+			q++
+		}
+	}
+	return saturate(float64(q))
+}
+
+// DivRatio divides a dimension by the ratio n/m, i.e. multiplies it by m/n.
+// See MulRatio.
+func (d Dimen) DivRatio(n, m int64) Dimen {
+	return d.MulRatio(m, n)
+}
+
+// Min returns the smaller of two dimensions.
+func Min(a, b Dimen) Dimen {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of two dimensions.
+func Max(a, b Dimen) Dimen {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Clamp limits a dimension to the range [lo…hi].
+func Clamp(d, lo, hi Dimen) Dimen {
+	return Max(lo, Min(d, hi))
+}
+
+func saturate(x float64) Dimen {
+	if x >= math.MaxInt32 {
+		return MaxDimen
+	} else if x <= math.MinInt32 {
+		return MinDimen
+	}
+	return Dimen(x)
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -49,6 +49,8 @@ const (
 	SP Dimen = 1       // scaled point = BP / 65536
 	BP Dimen = 65536   // big point (PDF) = 1/72 inch
 	PT Dimen = 65291   // printers point 1/72.27 inch
+	PC Dimen = 783492  // pica = 12 PT
+	PX Dimen = 49152   // CSS pixel = 1/96 inch
 	MM Dimen = 185771  // millimeters
 	CM Dimen = 1857710 // centimeters
 	IN Dimen = 4718592 // inch
@@ -76,9 +78,8 @@ func (d Dimen) Points() float64 {
 	return float64(d) / float64(BP)
 }
 
-// Point is a point on a page.
-//
-// TODO see methods in https://golang.org/pkg/image/#Point
+// Point is a point on a page. Points are also used as vectors and sizes.
+// Methods follow those of https://golang.org/pkg/image/#Point.
 type Point struct {
 	X, Y Dimen
 }
@@ -90,7 +91,8 @@ func (p *Point) Shift(vector Point) *Point {
 	return p
 }
 
-// Rect is a rectangle (on a page).
+// Rect is a rectangle (on a page). Its corners are sorted if TopL.X <= BotR.X
+// and TopL.Y <= BotR.Y, see Canon.
 type Rect struct {
 	TopL, BotR Point
 }
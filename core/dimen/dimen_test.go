@@ -0,0 +1,159 @@
+package dimen
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		s string
+		d Dimen
+	}{
+		{"12pt", 12 * PT},
+		{"3.5mm", 650199},
+		{"1in", IN},
+		{"2pc", 2 * PC},
+		{"-10 bp", -10 * BP},
+		{".5cm", CM / 2},
+		{"0", 0},
+		{"0em", 0},
+		{"0.0ex", 0},
+	} {
+		d, err := Parse(tc.s)
+		if err != nil || d != tc.d {
+			t.Errorf("expected %q to be %s, is %s (%v)", tc.s, tc.d, d, err)
+		}
+	}
+	for _, s := range []string{"", "12", "pt", "12xx", "1e3pt", "1em", "40000in"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+	ctx := Context{Em: 10 * BP, Ex: 5 * BP, Units: map[string]Dimen{"pt": BP}}
+	if d, err := ctx.Parse("1.5em"); err != nil || d != 15*BP {
+		t.Errorf("expected 1.5em to be 15bp, is %s (%v)", d, err)
+	}
+	if d, err := ctx.Parse("2ex"); err != nil || d != 10*BP {
+		t.Errorf("expected 2ex to be 10bp, is %s (%v)", d, err)
+	}
+	if d, err := ctx.Parse("12pt"); err != nil || d != 12*BP {
+		t.Errorf("expected context unit pt to be a big point, is %s (%v)", d, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		d    Dimen
+		unit string
+		prec int
+		s    string
+	}{
+		{12 * PT, "pt", 2, "12.00pt"},
+		{IN, "mm", 1, "25.4mm"},
+		{BP / 2, "bp", -1, "0.5bp"},
+		{-3 * BP, "pt", 3, "-3.011pt"},
+		{BP, "em", 2, "65536sp"},
+	} {
+		if s := tc.d.Format(tc.unit, tc.prec); s != tc.s {
+			t.Errorf("expected %s to be formatted as %q, is %q", tc.d, tc.s, s)
+		}
+	}
+}
+
+func TestRatios(t *testing.T) {
+	if d := (10 * BP).MulRatio(3, 4); d != 7*BP+BP/2 {
+		t.Errorf("expected 3/4 of 10bp to be 7.5bp, is %s", d)
+	}
+	if d := Dimen(-5).MulRatio(1, 2); d != -3 {
+		t.Errorf("expected -5sp/2 to round to -3sp, is %s", d)
+	}
+	if d := (100 * IN).MulRatio(1000, 1); d != MaxDimen {
+		t.Errorf("expected overflow to saturate, is %s", d)
+	}
+	if d := (-BP).DivRatio(0, 1); d != MinDimen {
+		t.Errorf("expected division by zero to saturate, is %s", d)
+	}
+	if d := (10 * BP).DivRatio(5, 2); d != 4*BP {
+		t.Errorf("expected 10bp/(5/2) to be 4bp, is %s", d)
+	}
+}
+
+func TestClamp(t *testing.T) {
+	if Min(BP, PT) != PT || Max(BP, PT) != BP {
+		t.Errorf("expected min(bp,pt) = pt and max(bp,pt) = bp")
+	}
+	if d := Clamp(20*BP, 0, 10*BP); d != 10*BP {
+		t.Errorf("expected 20bp to be clamped to 10bp, is %s", d)
+	}
+	if d := Clamp(-BP, 0, 10*BP); d != 0 {
+		t.Errorf("expected -1bp to be clamped to 0, is %s", d)
+	}
+}
+
+func TestRectGeometry(t *testing.T) {
+	r := Rectangle(100*BP, 50*BP, 0, 0)
+	if r.TopL != Origin || r.Width() != 100*BP || r.Height() != 50*BP {
+		t.Errorf("expected rectangle of 100bp x 50bp at origin, is %s", r)
+	}
+	s := r.Translate(Point{50 * BP, 25 * BP})
+	if i := r.Intersect(s); i != Rectangle(50*BP, 25*BP, 100*BP, 50*BP) {
+		t.Errorf("unexpected intersection %s", i)
+	}
+	if u := r.Union(s); u.Size() != (Point{150 * BP, 75 * BP}) {
+		t.Errorf("unexpected union %s", u)
+	}
+	if !r.Overlaps(s) || r.Overlaps(r.Translate(Point{100 * BP, 0})) {
+		t.Errorf("expected rectangles to overlap only if sharing an area")
+	}
+	if !r.Contains(Origin) || r.Contains(r.BotR) {
+		t.Errorf("expected rectangles to contain their top left corner only")
+	}
+	in := r.Inset(Insets{Top: 5 * BP, Right: 10 * BP, Bottom: 5 * BP, Left: 10 * BP})
+	if in != Rectangle(10*BP, 5*BP, 90*BP, 45*BP) || !r.ContainsRect(in) {
+		t.Errorf("unexpected inset rectangle %s", in)
+	}
+	if out := in.Outset(Insets{5 * BP, 10 * BP, 5 * BP, 10 * BP}); out != r {
+		t.Errorf("expected outset to revert inset, is %s", out)
+	}
+	if e := r.Inset(UniformInsets(40 * BP)); e.Height() != 0 || e.TopL.Y != 25*BP {
+		t.Errorf("expected inset to collapse to a line, is %s", e)
+	}
+}
+
+func TestAffine(t *testing.T) {
+	p := Point{10 * BP, 0}
+	if q := p.Transform(Rotation(math.Pi / 2)); q != (Point{0, 10 * BP}) {
+		t.Errorf("expected rotated point to be (0,10bp), is %s", q)
+	}
+	tr := Scaling(2, 2).Then(Translation(Point{BP, BP}))
+	if q := p.Transform(tr); q != (Point{21 * BP, BP}) {
+		t.Errorf("expected scaled and translated point (21bp,1bp), is %s", q)
+	}
+	r := Rectangle(0, 0, 10*BP, 20*BP).Transform(Rotation(math.Pi / 2))
+	if r != Rectangle(-20*BP, 0, 0, 10*BP) {
+		t.Errorf("unexpected bounding box of rotated rectangle %s", r)
+	}
+}
+
+func TestPaperSizes(t *testing.T) {
+	if Letter.X.Format("bp", 0) != "612bp" || Letter.Y.Format("bp", 0) != "792bp" {
+		t.Errorf("expected letter size to be 612bp x 792bp, is %s", Letter)
+	}
+	for _, tc := range []struct {
+		s     string
+		paper Point
+	}{
+		{"A4", DINA4},
+		{"legal", Legal},
+		{"a3 landscape", Point{420 * MM, 297 * MM}},
+		{"120mm x 6in", Point{120 * MM, 6 * IN}},
+	} {
+		if p, err := ParsePaperSize(tc.s); err != nil || p != tc.paper {
+			t.Errorf("expected paper size %q to be %s, is %s (%v)", tc.s, tc.paper, p, err)
+		}
+	}
+	if _, err := ParsePaperSize("quarto"); err == nil {
+		t.Errorf("expected unknown paper size to be rejected")
+	}
+}
@@ -0,0 +1,230 @@
+package dimen
+
+import (
+	"fmt"
+	"math"
+)
+
+// Coordinates grow to the right and downwards, i.e. the top left corner of a
+// rectangle has the smaller coordinates. Rectangles are half-open: they
+// contain their top and left edges, but not their bottom and right edges.
+
+// Add returns the vector p+q.
+func (p Point) Add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+// Sub returns the vector p-q.
+func (p Point) Sub(q Point) Point {
+	return Point{p.X - q.X, p.Y - q.Y}
+}
+
+// In reports whether p is in r.
+func (p Point) In(r Rect) bool {
+	return r.TopL.X <= p.X && p.X < r.BotR.X && r.TopL.Y <= p.Y && p.Y < r.BotR.Y
+}
+
+// Transform applies an affine transformation to a point.
+func (p Point) Transform(t Affine) Point {
+	x, y := float64(p.X), float64(p.Y)
+	return Point{
+		saturate(math.Round(t[0]*x + t[2]*y + t[4])),
+		saturate(math.Round(t[1]*x + t[3]*y + t[5])),
+	}
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("(%s,%s)", p.X, p.Y)
+}
+
+// Rectangle returns the rectangle with corners (x0,y0) and (x1,y1), in
+// canonical form.
+func Rectangle(x0, y0, x1, y1 Dimen) Rect {
+	return Rect{Point{x0, y0}, Point{x1, y1}}.Canon()
+}
+
+// Width returns the width of a rectangle.
+func (r Rect) Width() Dimen {
+	return r.BotR.X - r.TopL.X
+}
+
+// Height returns the height of a rectangle.
+func (r Rect) Height() Dimen {
+	return r.BotR.Y - r.TopL.Y
+}
+
+// Size returns the width and height of a rectangle.
+func (r Rect) Size() Point {
+	return r.BotR.Sub(r.TopL)
+}
+
+// Empty reports whether a rectangle contains no points.
+func (r Rect) Empty() bool {
+	return r.TopL.X >= r.BotR.X || r.TopL.Y >= r.BotR.Y
+}
+
+// Canon returns the canonical version of a rectangle, with the corners sorted
+// into the correct order.
+func (r Rect) Canon() Rect {
+	if r.TopL.X > r.BotR.X {
+		r.TopL.X, r.BotR.X = r.BotR.X, r.TopL.X
+	}
+	if r.TopL.Y > r.BotR.Y {
+		r.TopL.Y, r.BotR.Y = r.BotR.Y, r.TopL.Y
+	}
+	return r
+}
+
+// Translate returns a rectangle moved along a vector.
+func (r Rect) Translate(vector Point) Rect {
+	return Rect{r.TopL.Add(vector), r.BotR.Add(vector)}
+}
+
+// Contains reports whether a point is in r.
+func (r Rect) Contains(p Point) bool {
+	return p.In(r)
+}
+
+// ContainsRect reports whether s is completely inside r. An empty rectangle
+// is contained in every rectangle.
+func (r Rect) ContainsRect(s Rect) bool {
+	if s.Empty() {
+		return true
+	}
+	return r.TopL.X <= s.TopL.X && s.BotR.X <= r.BotR.X &&
+		r.TopL.Y <= s.TopL.Y && s.BotR.Y <= r.BotR.Y
+}
+
+// Overlaps reports whether r and s have a non-empty intersection.
+func (r Rect) Overlaps(s Rect) bool {
+	return !r.Intersect(s).Empty()
+}
+
+// Intersect returns the largest rectangle contained by both r and s. If they
+// do not overlap, the zero rectangle is returned.
+func (r Rect) Intersect(s Rect) Rect {
+	r = Rect{
+		Point{Max(r.TopL.X, s.TopL.X), Max(r.TopL.Y, s.TopL.Y)},
+		Point{Min(r.BotR.X, s.BotR.X), Min(r.BotR.Y, s.BotR.Y)},
+	}
+	if r.Empty() {
+		return Rect{}
+	}
+	return r
+}
+
+// Union returns the smallest rectangle that contains both r and s. Empty
+// rectangles are ignored.
+func (r Rect) Union(s Rect) Rect {
+	if r.Empty() {
+		return s
+	}
+	if s.Empty() {
+		return r
+	}
+	return Rect{
+		Point{Min(r.TopL.X, s.TopL.X), Min(r.TopL.Y, s.TopL.Y)},
+		Point{Max(r.BotR.X, s.BotR.X), Max(r.BotR.Y, s.BotR.Y)},
+	}
+}
+
+// Insets are distances from the four sides of a rectangle, e.g. padding or
+// margins of a box.
+type Insets struct {
+	Top, Right, Bottom, Left Dimen
+}
+
+// UniformInsets returns insets of the same size on every side.
+func UniformInsets(d Dimen) Insets {
+	return Insets{d, d, d, d}
+}
+
+// Inset returns a rectangle shrunk by insets, e.g. the content area of a box
+// with padding. The result will not be smaller than a line or a point,
+// located halfway between the sides.
+func (r Rect) Inset(in Insets) Rect {
+	r.TopL.X += in.Left
+	r.TopL.Y += in.Top
+	r.BotR.X -= in.Right
+	r.BotR.Y -= in.Bottom
+	if r.TopL.X > r.BotR.X {
+		r.TopL.X = r.TopL.X - (r.TopL.X-r.BotR.X)/2
+		r.BotR.X = r.TopL.X
+	}
+	if r.TopL.Y > r.BotR.Y {
+		r.TopL.Y = r.TopL.Y - (r.TopL.Y-r.BotR.Y)/2
+		r.BotR.Y = r.TopL.Y
+	}
+	return r
+}
+
+// Outset returns a rectangle enlarged by insets, e.g. the margin area of a box.
+func (r Rect) Outset(in Insets) Rect {
+	return Rect{
+		Point{r.TopL.X - in.Left, r.TopL.Y - in.Top},
+		Point{r.BotR.X + in.Right, r.BotR.Y + in.Bottom},
+	}
+}
+
+// Transform applies an affine transformation to a rectangle and returns the
+// bounding box of the result.
+func (r Rect) Transform(t Affine) Rect {
+	corners := [4]Point{r.TopL, {r.BotR.X, r.TopL.Y}, r.BotR, {r.TopL.X, r.BotR.Y}}
+	var bbox Rect
+	for i, c := range corners {
+		c = c.Transform(t)
+		if i == 0 {
+			bbox = Rect{c, c}
+			continue
+		}
+		bbox.TopL = Point{Min(bbox.TopL.X, c.X), Min(bbox.TopL.Y, c.Y)}
+		bbox.BotR = Point{Max(bbox.BotR.X, c.X), Max(bbox.BotR.Y, c.Y)}
+	}
+	return bbox
+}
+
+func (r Rect) String() string {
+	return fmt.Sprintf("[%s-%s]", r.TopL, r.BotR)
+}
+
+// Affine is an affine transformation [a b c d e f], mapping (x,y) to
+//
+//	x' = a·x + c·y + e
+//	y' = b·x + d·y + f
+//
+// This is the convention of PDF and PostScript.
+type Affine [6]float64
+
+// Identity returns the identity transformation.
+func Identity() Affine {
+	return Affine{1, 0, 0, 1, 0, 0}
+}
+
+// Translation returns a transformation which moves points along a vector.
+func Translation(vector Point) Affine {
+	return Affine{1, 0, 0, 1, float64(vector.X), float64(vector.Y)}
+}
+
+// Scaling returns a transformation which scales by sx and sy.
+func Scaling(sx, sy float64) Affine {
+	return Affine{sx, 0, 0, sy, 0, 0}
+}
+
+// Rotation returns a transformation which rotates around the origin by
+// angle theta (in radians), clockwise on a page with y growing downwards.
+func Rotation(theta float64) Affine {
+	sin, cos := math.Sincos(theta)
+	return Affine{cos, sin, -sin, cos, 0, 0}
+}
+
+// Then returns the transformation which applies t first and u second.
+func (t Affine) Then(u Affine) Affine {
+	return Affine{
+		t[0]*u[0] + t[1]*u[2],
+		t[0]*u[1] + t[1]*u[3],
+		t[2]*u[0] + t[3]*u[2],
+		t[2]*u[1] + t[3]*u[3],
+		t[4]*u[0] + t[5]*u[2] + u[4],
+		t[4]*u[1] + t[5]*u[3] + u[5],
+	}
+}
@@ -0,0 +1,63 @@
+package dimen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Paper sizes, in portrait orientation. See also DINA4 and DINA5.
+var (
+	DINA3  = Point{297 * MM, 420 * MM}
+	DINB5  = Point{176 * MM, 250 * MM}
+	Letter = Point{IN * 17 / 2, 11 * IN}
+	Legal  = Point{IN * 17 / 2, 14 * IN}
+)
+
+var paperSizes = map[string]Point{
+	"a3":     DINA3,
+	"a4":     DINA4,
+	"a5":     DINA5,
+	"b5":     DINB5,
+	"letter": Letter,
+	"legal":  Legal,
+}
+
+// Landscape returns a paper size in landscape orientation.
+func Landscape(paper Point) Point {
+	if paper.X < paper.Y {
+		return Point{paper.Y, paper.X}
+	}
+	return paper
+}
+
+// ParsePaperSize returns the size of a paper given by name ("A3", "A4", "A5",
+// "B5", "Letter" or "Legal") or as a custom size "<width> x <height>", e.g.
+// "120mm x 6in". The size may be followed by "landscape".
+func ParsePaperSize(s string) (Point, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	landscape := strings.HasSuffix(s, "landscape")
+	s = strings.TrimSpace(strings.TrimSuffix(s, "landscape"))
+	paper, ok := paperSizes[s]
+	if !ok {
+		wh := strings.Fields(s)
+		if len(wh) != 3 || wh[1] != "x" {
+			return Point{}, fmt.Errorf("unknown paper size %q", s)
+		}
+		w, err := Parse(wh[0])
+		if err != nil {
+			return Point{}, err
+		}
+		h, err := Parse(wh[2])
+		if err != nil {
+			return Point{}, err
+		}
+		if w <= 0 || h <= 0 {
+			return Point{}, fmt.Errorf("invalid paper size %q", s)
+		}
+		paper = Point{w, h}
+	}
+	if landscape {
+		paper = Landscape(paper)
+	}
+	return paper, nil
+}
@@ -0,0 +1,102 @@
+package dimen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// units are the absolute units known to Parse and Format. Units follow TeX,
+// i.e. "pt" is a printer's point and "bp" is a big (PDF) point.
+var units = map[string]Dimen{
+	"sp": SP,
+	"bp": BP,
+	"pt": PT,
+	"pc": PC,
+	"mm": MM,
+	"cm": CM,
+	"in": IN,
+	"px": PX,
+}
+
+// Unit returns the size of an absolute unit, e.g. Unit("mm") = MM.
+func Unit(name string) (Dimen, bool) {
+	u, ok := units[name]
+	return u, ok
+}
+
+// Context provides the sizes of font-relative units for parsing dimensions.
+// Units may contain additional or deviating units, e.g. for CSS, where
+// points are big points: Units{"pt": BP}.
+type Context struct {
+	Em, Ex Dimen            // sizes of units "em" and "ex"
+	Units  map[string]Dimen // more units, taking precedence over absolute units
+}
+
+// Parse parses a dimension from a string consisting of a decimal number and
+// a unit, e.g. "12pt", "3.5mm" or "-1in". Units are sp, bp, pt, pc, mm, cm, in
+// and px. A unit may be omitted for zero only.
+//
+// Font-relative units em and ex need a context, see Context.Parse. Zero
+// is accepted with any unit, e.g. "0em".
+func Parse(s string) (Dimen, error) {
+	return Context{}.Parse(s)
+}
+
+// Parse parses a dimension from a string (see package-level Parse),
+// with units em and ex and additional units taken from the context.
+func (ctx Context) Parse(s string) (Dimen, error) {
+	s = strings.TrimSpace(s)
+	split := strings.LastIndexFunc(s, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.'
+	}) + 1
+	num, name := strings.TrimSpace(s[:split]), strings.TrimSpace(s[split:])
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || strings.ContainsAny(num, "eEnNiI") { // no exponents, NaN or Inf
+		return 0, fmt.Errorf("invalid dimension %q", s)
+	}
+	if name == "" {
+		if f == 0 {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("missing unit in dimension %q", s)
+	}
+	unit, ok := ctx.unit(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q in dimension %q", name, s)
+	} else if unit == 0 && f != 0 { // em or ex without a context
+		return 0, fmt.Errorf("unit %q needs a context in dimension %q", name, s)
+	}
+	d := math.Round(f * float64(unit))
+	if d > math.MaxInt32 || d < math.MinInt32 {
+		return 0, fmt.Errorf("dimension too large: %q", s)
+	}
+	return Dimen(d), nil
+}
+
+func (ctx Context) unit(name string) (Dimen, bool) {
+	if u, ok := ctx.Units[name]; ok {
+		return u, true
+	}
+	switch name {
+	case "em":
+		return ctx.Em, true
+	case "ex":
+		return ctx.Ex, true
+	}
+	return Unit(name)
+}
+
+// Format formats a dimension in a unit (see Unit), rounded to prec decimal
+// places, e.g. (12*PT).Format("pt", 2) = "12.00pt". A negative precision
+// uses the smallest number of digits necessary. Dimensions are formatted
+// in scaled points if the unit is unknown.
+func (d Dimen) Format(unit string, prec int) string {
+	u, ok := Unit(unit)
+	if !ok {
+		return d.String()
+	}
+	return strconv.FormatFloat(float64(d)/float64(u), 'f', prec, 64) + unit
+}
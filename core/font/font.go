@@ -71,6 +71,7 @@ package font
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -78,6 +79,7 @@ import (
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
 )
 
 type ScalableFont struct {
@@ -144,3 +146,19 @@ func (tc *TypeCase) ScalableFontParent() *ScalableFont {
 func (tc *TypeCase) PtSize() float64 {
 	return tc.size
 }
+
+// GlyphBounds returns the height above and the depth below the baseline of a
+// glyph, in points. Parameter glyph is a glyph index, as returned by a text shaper.
+func (tc *TypeCase) GlyphBounds(glyph rune) (float64, float64, error) {
+	if tc.scalableFontParent == nil || tc.scalableFontParent.SFNT == nil {
+		return 0, 0, errors.New("type case is not connected to a font")
+	}
+	ppem := fixed.Int26_6(tc.size * 64.0)
+	bounds, _, err := tc.scalableFontParent.SFNT.GlyphBounds(&sfnt.Buffer{},
+		sfnt.GlyphIndex(glyph), ppem, font.HintingNone)
+	if err != nil {
+		return 0, 0, err
+	}
+	// y-axis points down, i.e. the top of a glyph has a negative y-coordinate
+	return float64(-bounds.Min.Y) / 64.0, float64(bounds.Max.Y) / 64.0, nil
+}
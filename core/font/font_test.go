@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/npillmayer/gotype/gtlocate"
+	"golang.org/x/image/font/sfnt"
 )
 
 func TestOpenOpenTypeLoading(t *testing.T) {
@@ -31,3 +32,20 @@ func TestOpenOpenTypeCaseCreation(t *testing.T) {
 	metrics := tc.font.Metrics()
 	fmt.Printf("interline spacing for [%s]@%.1fpt is %s\n", f.Fontname, tc.size, metrics.Height)
 }
+
+func TestGlyphBounds(t *testing.T) {
+	fontpath := gtlocate.FileResource("GentiumPlus-R.ttf", "font")
+	f, err := LoadOpenTypeFont(fontpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, _ := f.PrepareCase(12.0)
+	gid, _ := f.SFNT.GlyphIndex(&sfnt.Buffer{}, 'g')
+	h, d, err := tc.GlyphBounds(rune(gid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h <= 0 || h > 12.0 || d <= 0 || d > 12.0 {
+		t.Errorf("expected 'g' to have height and depth within 12pt, are %.2f and %.2f", h, d)
+	}
+}
@@ -0,0 +1,208 @@
+package hyphenation
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Compiled dictionaries may be written to a binary cache, which loads much
+// faster than parsing a TeX pattern file. The format is:
+//
+//     magic "GTHYPH", version byte
+//     identifier
+//     exceptions: count, then word and positions for every exception
+//     trie: first, labels, targets, valstart and vals
+//
+// Strings and slices are prefixed by their length, integers are stored as
+// unsigned varints.
+
+const (
+	cacheMagic   = "GTHYPH"
+	cacheVersion = 1
+)
+
+// WriteTo writes a compiled dictionary to a binary cache. It implements
+// io.WriterTo.
+func (dict *Dictionary) WriteTo(w io.Writer) (int64, error) {
+	cw := &cacheWriter{w: bufio.NewWriter(w)}
+	cw.bytes([]byte(cacheMagic))
+	cw.bytes([]byte{cacheVersion})
+	cw.str(dict.Identifier)
+	dict.mx.RLock()
+	defer dict.mx.RUnlock()
+	words := make([]string, 0, len(dict.exceptions))
+	for word := range dict.exceptions {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	cw.uint(uint64(len(words)))
+	for _, word := range words {
+		cw.str(word)
+		positions := dict.exceptions[word]
+		cw.uint(uint64(len(positions)))
+		for _, p := range positions {
+			cw.uint(uint64(p))
+		}
+	}
+	t := dict.patterns
+	cw.int32s(t.first)
+	cw.uint(uint64(len(t.labels)))
+	for _, r := range t.labels {
+		cw.uint(uint64(r))
+	}
+	cw.int32s(t.targets)
+	cw.int32s(t.valstart)
+	cw.uint(uint64(len(t.vals)))
+	cw.bytes(t.vals)
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ReadDictionary reads a compiled dictionary from a binary cache, as written
+// by Dictionary.WriteTo.
+func ReadDictionary(r io.Reader) (*Dictionary, error) {
+	cr := &cacheReader{r: bufio.NewReader(r)}
+	if magic := cr.bytes(len(cacheMagic) + 1); cr.err == nil &&
+		(string(magic[:len(cacheMagic)]) != cacheMagic || magic[len(cacheMagic)] != cacheVersion) {
+		return nil, errors.New("not a hyphenation dictionary cache, or incompatible version")
+	}
+	dict := &Dictionary{exceptions: make(map[string][]int)}
+	dict.Identifier = cr.str()
+	for i := cr.length(); i > 0 && cr.err == nil; i-- {
+		word := cr.str()
+		positions := make([]int, cr.length())
+		for j := range positions {
+			positions[j] = int(cr.uint())
+		}
+		dict.exceptions[word] = positions
+	}
+	t := &patternTrie{}
+	t.first = cr.int32s()
+	t.labels = make([]rune, cr.length())
+	for i := range t.labels {
+		t.labels[i] = rune(cr.uint())
+	}
+	t.targets = cr.int32s()
+	t.valstart = cr.int32s()
+	t.vals = cr.bytes(cr.length())
+	if cr.err != nil {
+		return nil, fmt.Errorf("cannot read hyphenation dictionary cache: %w", cr.err)
+	}
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	dict.patterns = t
+	return dict, nil
+}
+
+// check validates the structure of a trie read from a cache.
+func (t *patternTrie) check() error {
+	nodes := len(t.first) - 1
+	if nodes < 1 || len(t.valstart) != nodes+1 || len(t.targets) != len(t.labels) ||
+		int(t.first[nodes]) != len(t.labels) || int(t.valstart[nodes]) != len(t.vals) {
+		return errors.New("corrupt hyphenation dictionary cache")
+	}
+	for _, target := range t.targets {
+		if target <= 0 || int(target) >= nodes {
+			return errors.New("corrupt hyphenation dictionary cache")
+		}
+	}
+	for n := 0; n < nodes; n++ {
+		if t.first[n] > t.first[n+1] || t.valstart[n] > t.valstart[n+1] {
+			return errors.New("corrupt hyphenation dictionary cache")
+		}
+	}
+	return nil
+}
+
+// --- Helpers ---------------------------------------------------------------
+
+// cacheWriter writes to a buffered writer, remembering the first error.
+type cacheWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *cacheWriter) bytes(b []byte) {
+	if cw.err != nil {
+		return
+	}
+	var n int
+	n, cw.err = cw.w.Write(b)
+	cw.n += int64(n)
+}
+
+func (cw *cacheWriter) uint(x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	cw.bytes(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+func (cw *cacheWriter) str(s string) {
+	cw.uint(uint64(len(s)))
+	cw.bytes([]byte(s))
+}
+
+func (cw *cacheWriter) int32s(a []int32) {
+	cw.uint(uint64(len(a)))
+	for _, x := range a {
+		cw.uint(uint64(x))
+	}
+}
+
+// cacheReader reads from a buffered reader, remembering the first error.
+type cacheReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+// maxCacheLength limits the lengths of slices in a cache, to guard against
+// corrupt input.
+const maxCacheLength = 1 << 26
+
+func (cr *cacheReader) uint() uint64 {
+	if cr.err != nil {
+		return 0
+	}
+	var x uint64
+	x, cr.err = binary.ReadUvarint(cr.r)
+	return x
+}
+
+func (cr *cacheReader) length() int {
+	l := cr.uint()
+	if l > maxCacheLength {
+		if cr.err == nil {
+			cr.err = errors.New("length out of range")
+		}
+		return 0
+	}
+	return int(l)
+}
+
+func (cr *cacheReader) bytes(n int) []byte {
+	if cr.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, cr.err = io.ReadFull(cr.r, b)
+	return b
+}
+
+func (cr *cacheReader) str() string {
+	return string(cr.bytes(cr.length()))
+}
+
+func (cr *cacheReader) int32s() []int32 {
+	a := make([]int32, cr.length())
+	for i := range a {
+		a[i] = int32(cr.uint())
+	}
+	return a
+}
@@ -0,0 +1,138 @@
+package hyphenation
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Exceptions are words with explicitly given hyphenation positions, which take
+// precedence over patterns, similar to TeX's \hyphenation command.
+// Exception words are written with hyphens at legal hyphenation positions, e.g.,
+// "ta-ble". An exception word without hyphens will never be hyphenated.
+// Exceptions are looked up case-insensitively.
+//
+// Dictionaries may be shared, e.g. if cached by package locate. Clients which
+// add or remove exceptions, e.g. for a single document, have to work on a
+// clone of a shared dictionary (see Clone).
+
+// Clone returns a copy of the dictionary, with its own set of exceptions.
+// Patterns are immutable and shared between the copies.
+func (dict *Dictionary) Clone() *Dictionary {
+	dict.mx.RLock()
+	defer dict.mx.RUnlock()
+	clone := &Dictionary{
+		exceptions: make(map[string][]int, len(dict.exceptions)),
+		patterns:   dict.patterns,
+		Identifier: dict.Identifier,
+	}
+	for word, positions := range dict.exceptions {
+		clone.exceptions[word] = positions
+	}
+	return clone
+}
+
+// AddException adds an exception word to the dictionary, e.g., "ta-ble".
+// An existing exception for the word is replaced.
+func (dict *Dictionary) AddException(hyphenated string) {
+	word, positions := parseException(strings.TrimSpace(hyphenated))
+	if word == "" {
+		return
+	}
+	dict.mx.Lock()
+	defer dict.mx.Unlock()
+	dict.exceptions[strings.ToLower(word)] = positions
+}
+
+// NeverHyphenate marks a word as not to be hyphenated.
+func (dict *Dictionary) NeverHyphenate(word string) {
+	dict.AddException(strings.Replace(word, "-", "", -1))
+}
+
+// RemoveException removes an exception word from the dictionary. The word may
+// be given with or without hyphens. Returns false if the word is not an exception.
+// After removing an exception, the word will be hyphenated by patterns.
+func (dict *Dictionary) RemoveException(word string) bool {
+	word = strings.ToLower(strings.Replace(word, "-", "", -1))
+	dict.mx.Lock()
+	defer dict.mx.Unlock()
+	_, found := dict.exceptions[word]
+	delete(dict.exceptions, word)
+	return found
+}
+
+// Exceptions returns all exception words of the dictionary, with hyphens
+// inserted at legal positions, in alphabetical order.
+func (dict *Dictionary) Exceptions() []string {
+	dict.mx.RLock()
+	defer dict.mx.RUnlock()
+	words := make([]string, 0, len(dict.exceptions))
+	for word, positions := range dict.exceptions {
+		words = append(words, strings.Join(splitAtPositions(word, positions), "-"))
+	}
+	sort.Strings(words)
+	return words
+}
+
+// ReadExceptions reads a list of exception words, separated by whitespace.
+// Comments start with '%' and extend to the end of the line. A TeX
+// "\hyphenation{…}" wrapper around the words is accepted, so exceptions may
+// be shared with TeX documents.
+func (dict *Dictionary) ReadExceptions(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexRune(line, '%'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.Replace(line, "\\hyphenation{", " ", -1)
+		line = strings.Replace(line, "}", " ", -1)
+		for _, word := range strings.Fields(line) {
+			dict.AddException(word)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadExceptions reads a file of exception words, e.g., for a project.
+// See ReadExceptions for the format of the file.
+func (dict *Dictionary) LoadExceptions(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return dict.ReadExceptions(file)
+}
+
+// exception looks up an exception word.
+func (dict *Dictionary) exception(word string) ([]int, bool) {
+	dict.mx.RLock()
+	defer dict.mx.RUnlock()
+	positions, found := dict.exceptions[word]
+	if !found {
+		positions, found = dict.exceptions[strings.ToLower(word)]
+	}
+	return positions, found
+}
+
+// parseException extracts a word and its hyphenation positions from an
+// exception, e.g., "ta-ble" => "table", [0,0,1,0,0].
+func parseException(hyphenated string) (string, []int) {
+	var positions []int // we'll extract positions
+	washyphen := false
+	for _, char := range hyphenated {
+		if char == '-' {
+			positions = append(positions, 1) // possible break point
+			washyphen = true
+		} else if washyphen { // skip letter
+			washyphen = false
+		} else { // a letter without a '-'
+			positions = append(positions, 0) // append 0
+		}
+	}
+	word := strings.Replace(hyphenated, "-", "", -1)
+	return word, positions
+}
@@ -1,9 +1,15 @@
 package hyphenation
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"unicode"
 
+	"github.com/derekparker/trie"
 	"github.com/npillmayer/gotype/core/config/configtestadapter"
 	"github.com/npillmayer/gotype/core/config/gconf"
 	"github.com/npillmayer/gotype/core/config/tracing/gotestingadapter"
@@ -16,10 +22,16 @@ func init() {
 var germanDict, usDict *Dictionary
 
 func init() {
-	germanDict = LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-de-1996.tex")
+	var err error
+	germanDict, err = LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-de-1996.tex")
+	if err != nil {
+		panic(err)
+	}
 	fmt.Printf("%s\n", germanDict.Identifier)
-	usDict = LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-en-us.tex")
-	//usDict = LoadPatterns("/Users/npi/prg/go/gotype/etc/hyph-en-us.tex")
+	usDict, err = LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-en-us.tex")
+	if err != nil {
+		panic(err)
+	}
 	fmt.Printf("%s\n", usDict.Identifier)
 }
 
@@ -82,3 +94,195 @@ func TestUSPatterns(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestDictionaryCache(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	var buf bytes.Buffer
+	if _, err := usDict.WriteTo(&buf); err != nil {
+		t.Fatalf("cannot write dictionary cache: %v", err)
+	}
+	t.Logf("cache for %s has %d bytes", usDict.Identifier, buf.Len())
+	dict, err := ReadDictionary(&buf)
+	if err != nil {
+		t.Fatalf("cannot read dictionary cache: %v", err)
+	}
+	for _, word := range []string{"hello", "table", "computer", "algorithm", "concatenation"} {
+		if h1, h2 := usDict.HyphenationString(word), dict.HyphenationString(word); h1 != h2 {
+			t.Errorf("expected cached dictionary to hyphenate %s as %s, is %s", word, h1, h2)
+		}
+	}
+	if _, err = ReadDictionary(strings.NewReader("GTHYPH")); err == nil {
+		t.Errorf("expected truncated cache to be rejected")
+	}
+	if _, err = LoadPatterns("no-such-file.tex"); err == nil {
+		t.Errorf("expected missing pattern file to be reported")
+	}
+}
+
+func TestPackedTrie(t *testing.T) {
+	b := newTrieBuilder()
+	b.add("a", []uint8{0, 1})
+	b.add("ab", []uint8{0, 2, 0})
+	b.add("b", []uint8{3, 0})
+	trie := b.pack()
+	if trie.size() != 4 {
+		t.Errorf("expected trie to have 4 nodes, has %d", trie.size())
+	}
+	levels := trie.apply([]rune("abc"))
+	if fmt.Sprint(levels) != "[0 3 0 0]" {
+		t.Errorf("expected levels [0 3 0 0], are %v", levels)
+	}
+}
+
+// --- Benchmarks ------------------------------------------------------------
+
+var benchWords = strings.Fields(`hyphenation algorithm concatenation computer
+	typesetting paragraph dictionary representation international characteristics
+	professional responsibilities encyclopedia mathematics`)
+
+func BenchmarkHyphenate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, word := range benchWords {
+			usDict.Hyphenate(word)
+		}
+	}
+}
+
+// BenchmarkHyphenateNaive benchmarks the previous implementation, which
+// looked up every prefix of every suffix of a word in a pointer-based trie.
+func BenchmarkHyphenateNaive(b *testing.B) {
+	patterns := loadNaivePatterns(b, gconf.GetString("etc-dir")+"/pattern/hyph-en-us.tex")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, word := range benchWords {
+			naiveHyphenate(word, patterns)
+		}
+	}
+}
+
+func BenchmarkLoadPatterns(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-en-us.tex"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadDictionary(b *testing.B) {
+	var buf bytes.Buffer
+	if _, err := usDict.WriteTo(&buf); err != nil {
+		b.Fatal(err)
+	}
+	cache := buf.Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadDictionary(bytes.NewReader(cache)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func loadNaivePatterns(b *testing.B, patternfile string) *trie.Trie {
+	file, err := os.Open(patternfile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	patterns := trie.New()
+	scanner := bufio.NewScanner(file)
+	inPatterns := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\\patterns{") {
+			inPatterns = true
+			continue
+		} else if !inPatterns || strings.HasPrefix(line, "%") || line == "" {
+			continue
+		} else if strings.HasPrefix(line, "}") {
+			break
+		}
+		var pattern string
+		var positions []int
+		wasdigit := false
+		for _, char := range line {
+			if unicode.IsDigit(char) {
+				positions = append(positions, int(char-'0'))
+				wasdigit = true
+			} else {
+				pattern += string(char)
+				if !wasdigit {
+					positions = append(positions, 0)
+				}
+				wasdigit = false
+			}
+		}
+		patterns.Add(pattern, positions)
+	}
+	return patterns
+}
+
+func naiveHyphenate(word string, patterns *trie.Trie) []int {
+	dottedword := "." + word + "."
+	positions := make([]int, 10)
+	l := len(dottedword)
+	for i := 0; i < l; i++ {
+		fragment := dottedword[i:l]
+		for j := 1; j < len(fragment); j++ {
+			if node, _ := patterns.Find(fragment[:j]); node != nil {
+				for relAt, num := range node.Meta().([]int) {
+					if i+relAt >= len(positions) {
+						positions = append(positions, 0)
+					}
+					if num > positions[i+relAt] {
+						positions[i+relAt] = num
+					}
+				}
+			}
+		}
+	}
+	return positions
+}
+
+func TestExceptions(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	dict, err := LoadPatterns(gconf.GetString("etc-dir") + "/pattern/hyph-en-us.tex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict.AddException("al-gor-ithm")
+	if h := dict.HyphenationString("Algorithm"); h != "Al-gor-ithm" {
+		t.Errorf("expected exception al-gor-ithm to be used, is %s", h)
+	}
+	dict.NeverHyphenate("computer")
+	if h := dict.HyphenationString("computer"); h != "computer" {
+		t.Errorf("expected computer not to be hyphenated, is %s", h)
+	}
+	if !dict.RemoveException("com-puter") || dict.HyphenationString("computer") != "com-put-er" {
+		t.Errorf("expected computer to be hyphenated by patterns after removing exception")
+	}
+	err = dict.ReadExceptions(strings.NewReader("% project exceptions\n\\hyphenation{ gno-mon\n  Go-Type }"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := dict.Clone()
+	clone.NeverHyphenate("hello")
+	clone.RemoveException("gno-mon")
+	if h := clone.HyphenationString("hello"); h != "hello" {
+		t.Errorf("expected clone not to hyphenate hello, is %s", h)
+	}
+	if dict.HyphenationString("hello") != "hel-lo" || dict.HyphenationString("gnomon") != "gno-mon" {
+		t.Errorf("expected exceptions of a clone not to affect the original dictionary")
+	}
+	if h := clone.HyphenationString("Algorithm"); h != "Al-gor-ithm" {
+		t.Errorf("expected clone to keep exception al-gor-ithm, is %s", h)
+	}
+	exceptions := " " + strings.Join(dict.Exceptions(), " ") + " "
+	t.Logf("exceptions = %s", exceptions)
+	for _, word := range []string{"al-gor-ithm", "gno-mon", "go-type"} {
+		if !strings.Contains(exceptions, " "+word+" ") {
+			t.Errorf("expected user exception %s to be listed", word)
+		}
+	}
+}
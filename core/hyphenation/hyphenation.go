@@ -3,13 +3,12 @@ package hyphenation
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 
-	"github.com/derekparker/trie" // TODO: replace this
 	"github.com/huandu/xstrings"
 )
 
@@ -44,22 +43,10 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
  * ----------------------------------------------------------------------
  *
-Quick and dirty implementation of a hyphenation algorithm.
-
-(TODO: make more time and space efficient).
-
 Package for an algorithm to hyphenate words. It is based on an algorithm
 described by Frank Liang (F.M.Liang http://www.tug.org/docs/liang/). It loads
-a pattern file (available with the TeX distribution) and builds a trie
-structure, carrying an array of positions at every 'leaf'.
-
-The trie package I'm using here is a very naive implementation and should
-be replaced by a more sophisticated one
-(e.g., https://github.com/siongui/go-succinct-data-structure-trie).
-https://hackthology.com/ternary-search-tries-for-fast-flexible-string-search-part-1.html
-Resulting from the API of the trie, the implementation of the pattern
-application algorithm is bad. TODO: improve time complexity of pattern
-application.
+a pattern file (available with the TeX distribution) and builds a packed trie
+(see trie.go), carrying an array of positions at every node where a pattern ends.
 
 Further reading:
 
@@ -71,12 +58,14 @@ http://www.mnn.ch/hyph/hyphenation2.html  / https://github.com/mnater/hyphenator
 // Dictionary is a type representing a hyphenation dictionary.
 // A hyphenation dictionary consists of hyphenation patterns and a list of exceptions
 type Dictionary struct {
+	mx         sync.RWMutex     // protects exceptions, which may change at runtime
 	exceptions map[string][]int // e.g., "computer" => [3,5] = "com-pu-ter"
-	patterns   *trie.Trie       // where we store patterns and positions
+	patterns   *patternTrie     // where we store patterns and positions
 	Identifier string           // Identifies the dictionary
 }
 
-// LoadPatterns loads a pattern file. Returns the identifier of the pattern file and a trie.
+// LoadPatterns loads a pattern file. Returns a dictionary for the patterns, or an error
+// if the pattern file cannot be read.
 //
 // Patterns are enclosed in between
 //
@@ -96,17 +85,17 @@ type Dictionary struct {
 //
 //    "a5ban" => (a)(5b)(a)(n) => positions["aban"] = [0,5,0,0].
 //
-func LoadPatterns(patternfile string) *Dictionary {
+func LoadPatterns(patternfile string) (*Dictionary, error) {
 	file, err := os.Open(patternfile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer file.Close()
 	dict := &Dictionary{
 		exceptions: make(map[string][]int),
-		patterns:   trie.New(),
 		Identifier: fmt.Sprintf("patterns: %s", patternfile),
 	}
+	patterns := newTrieBuilder()
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() { // internally, it advances token based on sperator
 		line := scanner.Text()
@@ -120,12 +109,12 @@ func LoadPatterns(patternfile string) *Dictionary {
 			// ignore comments, TeX commands, etc.
 		} else { // read and decode a pattern: ".ab1a" "abe4l3in", ...
 			var pattern string          // will become the pattern without positions
-			var positions []int         // we'll extract positions
+			var positions []uint8       // we'll extract positions
 			var wasdigit bool           // has the last char been a digit?
 			for _, char := range line { // iterate over runes of the pattern
 				if unicode.IsDigit(char) {
 					d, _ := strconv.Atoi(string(char))
-					positions = append(positions, d) // add to positions array
+					positions = append(positions, uint8(d)) // add to positions array
 					wasdigit = true
 				} else { // '.' or alphabetic rune
 					pattern = pattern + string(char)
@@ -137,10 +126,14 @@ func LoadPatterns(patternfile string) *Dictionary {
 				}
 			}
 			//fmt.Printf("pattern '%s'\thas positions %v\n", pattern, positions)
-			dict.patterns.Add(pattern, positions)
+			patterns.add(pattern, positions)
 		}
 	}
-	return dict
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read pattern file %s: %w", patternfile, err)
+	}
+	dict.patterns = patterns.pack()
+	return dict, nil
 }
 
 /*
@@ -162,19 +155,7 @@ func (dict *Dictionary) readExceptions(scanner *bufio.Scanner) {
 		if strings.HasPrefix(line, "}") {
 			return
 		}
-		var positions []int // we'll extract positions
-		washyphen := false
-		for _, char := range line {
-			if char == '-' {
-				positions = append(positions, 1) // possible break point
-				washyphen = true
-			} else if washyphen { // skip letter
-				washyphen = false
-			} else { // a letter without a '-'
-				positions = append(positions, 0) // append 0
-			}
-		}
-		word := strings.Replace(line, "-", "", -1)
+		word, positions := parseException(line)
 		dict.exceptions[word] = positions
 		//fmt.Printf("exception '%s'\thas positions %v\n", line, positions)
 	}
@@ -197,83 +178,39 @@ func (dict *Dictionary) HyphenationString(word string) string {
 //     "table" => [ "ta", "ble" ].
 //
 func (dict *Dictionary) Hyphenate(word string) []string {
-	if positions, found := dict.exceptions[word]; found {
+	if positions, found := dict.exception(word); found {
 		return splitAtPositions(word, positions)
 	}
-	dottedword := "." + word + "."
-	var positions = make([]int, 10) // the resulting hyphenation positions
-	l := len(dottedword)
-	for i := 0; i < l; i++ { // "word", "ord", "rd", "d"
-		pp := findPrefixPositions(dottedword[i:l], dict.patterns)
-		positions = mergePositions(positions, pp, i)
-	}
-	positions = positions[1 : len(positions)-1]
-	if positions[0] > 0 { // sometimes hyphen before first letter is "allowed"
-		positions[0] = 0
-	} else if len(positions) > len(word) && positions[len(word)] > 0 {
-		positions[len(word)] = 0 // sometimes hyphen after last letter is "allowed"
+	dottedword := []rune("." + word + ".")
+	levels := dict.patterns.apply(dottedword)
+	positions := make([]int, len(dottedword)-2) // position i is in front of rune i
+	for i := minFragment; i <= len(positions)-minFragment; i++ {
+		positions[i] = int(levels[i+1])
 	}
 	return splitAtPositions(word, positions)
 }
 
-/*
-For a given word fragment w, find trie entries for every prefix of w.
-Example: word fragement is "gment". Find entries for "gment", "ment",
-"ent", "nt", "t". Collect and return the resulting positions arrays.
-*/
-func findPrefixPositions(wordfragment string, trie *trie.Trie) [][]int {
-	l := len(wordfragment)
-	var pp [][]int           // return value
-	for j := 1; j < l; j++ { // for every char position until end of fragment
-		node, _ := trie.Find(wordfragment[:j])
-		if node != nil { // yes, entry found
-			pp = append(pp, node.Meta().([]int)) // Meta() is arr of positions
-		}
-	}
-	return pp
-}
-
-/*
-Merge a collection of positions arrays to a given positions array at
-a given index. Positions are overwritten, if a new position is greater
-than the old one. If the positions array isn't long enough, it will be
-enlarged.
-
-Example:
-
-    given p = [0,2,0,0] and pp = { [1,7], [0,0,3] } =>
-
-after merge at position 1:
-
-    p = [0,2,7,3].
-
-*/
-func mergePositions(positions []int, pp [][]int, at int) []int {
-	for _, p := range pp { // for all partial position arrays
-		for relAt, num := range p { // for evey relative position
-			if at+relAt >= len(positions) { // array long enough?
-				positions = append(positions, 0)
-			}
-			if num > positions[at+relAt] { // new pos greater than current pos?
-				positions[at+relAt] = num
-			}
-		}
-	}
-	return positions
-}
+// minFragment is the minimum number of runes before the first and after the last
+// hyphen of a word. TeX pattern files are generated for hyphenmins of at least 2,
+// and produce spurious hyphens closer to the edges of words. Clients may apply
+// larger limits, as with TeX's \lefthyphenmin and \righthyphenmin.
+const minFragment = 2
 
 /* Helper: split a string at positions given by an integer slice.
+ * Position i is in front of rune i of the word.
  */
 func splitAtPositions(word string, positions []int) []string {
 	var pp = make([]string, 0, len(word)/3)
-	prev := 0                       // holds the last split index
-	for i, pos := range positions { // check every position
-		if pos > 0 && pos%2 != 0 { // if position is odd > 0
-			pp = append(pp, word[prev:i]) // append syllable
-			prev = i                      // remember last split index
+	prev := 0              // holds the last split index
+	i := 0                 // rune index
+	for at := range word { // check every position
+		if i > 0 && i < len(positions) && positions[i]%2 != 0 { // if position is odd
+			pp = append(pp, word[prev:at]) // append syllable
+			prev = at                      // remember last split index
 		}
+		i++
 	}
-	pp = append(pp, word[prev:len(word)]) // append last syllable
+	pp = append(pp, word[prev:]) // append last syllable
 	return pp
 }
 
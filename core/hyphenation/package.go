@@ -1,19 +1,14 @@
 /*
-Package hyphenation is a quick and dirty implementation of a hyphenation algorithm.
-
-(TODO: make more time and space efficient).
+Package hyphenation implements a hyphenation algorithm.
 
 Package for an algorithm to hyphenate words. It is based on an algorithm
 described by Frank Liang (F.M.Liang http://www.tug.org/docs/liang/). It loads
-a pattern file (available with the TeX distribution) and builds a trie
-structure, carrying an array of positions at every 'leaf'.
-
-The trie package I'm using here is a very naive implementation and should
-be replaced by a more sophisticated one
-(e.g., https://github.com/siongui/go-succinct-data-structure-trie).
-Resulting from the API of the trie, the implementation of the pattern
-application algorithm is bad. TODO: improve time complexity of pattern
-application.
+a pattern file (available with the TeX distribution) and builds a packed trie,
+carrying an array of positions at every node where a pattern ends.
+Patterns are applied to a word in a single pass over the word.
+
+Parsing TeX pattern files is comparatively slow. Compiled dictionaries may be
+written to a binary cache with Dictionary.WriteTo and loaded with ReadDictionary.
 
 Further Reading
 
@@ -0,0 +1,128 @@
+package hyphenation
+
+import "sort"
+
+// A patternTrie is a packed trie of hyphenation patterns. Nodes are numbered,
+// with the root being node 0. All edges of the trie are stored in flat slices,
+// with the edges of a node being adjacent and sorted by label.
+//
+// Node n has edges [first[n]…first[n+1]) and pattern positions
+// [valstart[n]…valstart[n+1]), the latter being empty if no pattern ends
+// at node n.
+type patternTrie struct {
+	first    []int32 // index of the first edge of a node
+	labels   []rune  // edge labels
+	targets  []int32 // target nodes of edges
+	valstart []int32 // index of the first position of a node's pattern
+	vals     []uint8 // positions of patterns
+}
+
+// child returns the node reached from node n by an edge labelled r, or -1.
+func (t *patternTrie) child(n int32, r rune) int32 {
+	lo, hi := t.first[n], t.first[n+1]
+	for lo < hi { // binary search over sorted labels
+		m := lo + (hi-lo)/2
+		if t.labels[m] < r {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	if lo < t.first[n+1] && t.labels[lo] == r {
+		return t.targets[lo]
+	}
+	return -1
+}
+
+// positions returns the pattern positions for node n, or nil.
+func (t *patternTrie) positions(n int32) []uint8 {
+	if t.valstart[n] == t.valstart[n+1] {
+		return nil
+	}
+	return t.vals[t.valstart[n]:t.valstart[n+1]]
+}
+
+// size returns the number of nodes of the trie.
+func (t *patternTrie) size() int {
+	return len(t.first) - 1
+}
+
+// apply applies all patterns matching a (dotted) word in a single pass,
+// returning the maximum pattern value for every position in front of a rune
+// of the word.
+func (t *patternTrie) apply(word []rune) []uint8 {
+	levels := make([]uint8, len(word)+1)
+	for i := range word {
+		n := int32(0)
+		for j := i; j < len(word); j++ {
+			if n = t.child(n, word[j]); n < 0 {
+				break
+			}
+			for k, v := range t.positions(n) {
+				if i+k < len(levels) && v > levels[i+k] {
+					levels[i+k] = v
+				}
+			}
+		}
+	}
+	return levels
+}
+
+// --- Building a trie -------------------------------------------------------
+
+// trieBuilder collects patterns in a pointer-based trie, to be packed
+// after all patterns have been added.
+type trieBuilder struct {
+	root *builderNode
+}
+
+type builderNode struct {
+	children  map[rune]*builderNode
+	positions []uint8
+}
+
+func newTrieBuilder() *trieBuilder {
+	return &trieBuilder{root: &builderNode{}}
+}
+
+// add adds a pattern with its positions.
+func (b *trieBuilder) add(pattern string, positions []uint8) {
+	n := b.root
+	for _, r := range pattern {
+		if n.children == nil {
+			n.children = make(map[rune]*builderNode)
+		}
+		c, ok := n.children[r]
+		if !ok {
+			c = &builderNode{}
+			n.children[r] = c
+		}
+		n = c
+	}
+	n.positions = positions
+}
+
+// pack creates a packed trie, numbering nodes in breadth-first order.
+func (b *trieBuilder) pack() *patternTrie {
+	t := &patternTrie{}
+	queue := []*builderNode{b.root}
+	for i := 0; i < len(queue); i++ {
+		n := queue[i]
+		t.first = append(t.first, int32(len(t.labels)))
+		t.valstart = append(t.valstart, int32(len(t.vals)))
+		t.vals = append(t.vals, n.positions...)
+		labels := make([]rune, 0, len(n.children))
+		for r := range n.children {
+			labels = append(labels, r)
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
+		for _, r := range labels {
+			t.labels = append(t.labels, r)
+			t.targets = append(t.targets, int32(len(queue)))
+			queue = append(queue, n.children[r])
+		}
+	}
+	t.first = append(t.first, int32(len(t.labels)))
+	t.valstart = append(t.valstart, int32(len(t.vals)))
+	return t
+}
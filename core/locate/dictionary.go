@@ -0,0 +1,181 @@
+package locate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/npillmayer/gotype/core/hyphenation"
+	"golang.org/x/text/language"
+)
+
+// patternFiles maps languages to TeX hyphenation pattern files, as named by the
+// hyph-utf8 project. Pattern files are expected in GTROOT/pattern.
+// For a language, the first entry is the default for all its regions. Entries
+// with variants, e.g. "de-1901" for traditional German orthography, are used
+// only for language tags carrying the same variants.
+var patternFiles = []struct {
+	tag  language.Tag
+	file string
+}{
+	{language.AmericanEnglish, "hyph-en-us.tex"},
+	{language.BritishEnglish, "hyph-en-gb.tex"},
+	{language.German, "hyph-de-1996.tex"},
+	{language.MustParse("de-CH-1901"), "hyph-de-ch-1901.tex"},
+	{language.MustParse("de-1901"), "hyph-de-1901.tex"},
+	{language.French, "hyph-fr.tex"},
+	{language.Italian, "hyph-it.tex"},
+	{language.Spanish, "hyph-es.tex"},
+	{language.Dutch, "hyph-nl.tex"},
+	{language.Hungarian, "hyph-hu.tex"},
+}
+
+// patternMatcher matches languages against the entries of patternFiles without
+// variants; matchable maps the matcher's indices to indices of patternFiles.
+// The matcher does not respect variants and would, e.g., match "de-CH" to
+// "de-CH-1901".
+var patternMatcher, matchable = func() (language.Matcher, []int) {
+	var tags []language.Tag
+	var indices []int
+	for i, pf := range patternFiles {
+		if len(pf.tag.Variants()) == 0 {
+			tags = append(tags, pf.tag)
+			indices = append(indices, i)
+		}
+	}
+	return language.NewMatcher(tags), indices
+}()
+
+// PatternFile finds the hyphenation pattern file for a language, given as a
+// BCP 47 language tag (e.g., "de-CH"). Underscores are accepted as separators,
+// as in "en_GB". If there is no pattern file for a regional variant, the
+// default pattern file for the language is used. Variants select a specific
+// orthography, e.g., "de-1901" for traditional German orthography; without
+// a variant, the current orthography is used. If no pattern file for a
+// variant is installed, the default pattern file for the language is used.
+//
+// Returns an error if no pattern file is available for the language.
+func PatternFile(loc string) (string, error) {
+	candidates, err := patternCandidates(loc)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range candidates {
+		path := FileResource(file, "pattern")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no hyphenation pattern file installed for language %q", loc)
+}
+
+// patternCandidates returns the names of pattern files suitable for a language,
+// in order of preference.
+func patternCandidates(loc string) ([]string, error) {
+	tag, err := language.Parse(strings.Replace(loc, "_", "-", -1))
+	if err != nil {
+		if _, ok := err.(language.ValueError); !ok { // unknown subtags are acceptable
+			return nil, fmt.Errorf("invalid language %q: %w", loc, err)
+		}
+	}
+	_, index, confidence := patternMatcher.Match(tag)
+	if confidence == language.No {
+		return nil, fmt.Errorf("no hyphenation patterns for language %q", loc)
+	}
+	index = matchable[index]
+	base, _ := patternFiles[index].tag.Base()
+	var candidates []string
+	if variants := tag.Variants(); len(variants) > 0 {
+		region, _ := tag.Region()
+		for _, pf := range patternFiles { // entries for the tag's region first
+			b, _ := pf.tag.Base()
+			if r, c := pf.tag.Region(); b == base && c == language.Exact && r == region &&
+				sameVariants(pf.tag.Variants(), variants) {
+				candidates = append(candidates, pf.file)
+			}
+		}
+		for _, pf := range patternFiles {
+			b, _ := pf.tag.Base()
+			if _, c := pf.tag.Region(); b == base && c != language.Exact &&
+				sameVariants(pf.tag.Variants(), variants) {
+				candidates = append(candidates, pf.file)
+			}
+		}
+	}
+	candidates = append(candidates, patternFiles[index].file)
+	for _, pf := range patternFiles { // fall back to other regions of the language
+		if b, _ := pf.tag.Base(); b == base && len(pf.tag.Variants()) == 0 && pf.file != patternFiles[index].file {
+			candidates = append(candidates, pf.file)
+		}
+	}
+	return candidates, nil
+}
+
+func sameVariants(v1, v2 []language.Variant) bool {
+	if len(v1) != len(v2) {
+		return false
+	}
+	for i := range v1 {
+		if v1[i] != v2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// dictEntry is a cache entry for a dictionary, loaded at most once.
+type dictEntry struct {
+	once sync.Once
+	path string // pattern file
+	dict *hyphenation.Dictionary
+	err  error
+}
+
+var dicts = struct {
+	sync.Mutex
+	entries map[string]*dictEntry // pattern file => dictionary
+	locales map[string]*dictEntry // language tag => dictionary
+}{
+	entries: make(map[string]*dictEntry),
+	locales: make(map[string]*dictEntry),
+}
+
+// Dictionary returns a hyphenation dictionary for a language, given as a BCP 47
+// language tag (see PatternFile). Dictionaries are loaded once and cached,
+// as is the pattern file resolved for a language tag.
+// It is safe to call Dictionary from multiple goroutines.
+//
+// Dictionaries are shared between all callers for a language. Clients must
+// not add exceptions to them, but to a clone (see hyphenation.Dictionary.Clone).
+func Dictionary(loc string) (*hyphenation.Dictionary, error) {
+	dicts.Lock()
+	entry, ok := dicts.locales[loc]
+	if !ok {
+		entry = dictEntryFor(loc)
+		dicts.locales[loc] = entry
+	}
+	dicts.Unlock()
+	entry.once.Do(func() {
+		entry.dict, entry.err = hyphenation.LoadPatterns(entry.path)
+	})
+	return entry.dict, entry.err
+}
+
+// dictEntryFor resolves the pattern file for a language tag and returns the
+// cache entry for it. Language tags without a pattern file get an entry
+// holding the error. Callers must hold the lock on dicts.
+func dictEntryFor(loc string) *dictEntry {
+	path, err := PatternFile(loc)
+	if err != nil {
+		entry := &dictEntry{err: err}
+		entry.once.Do(func() {}) // nothing to load
+		return entry
+	}
+	entry, ok := dicts.entries[path]
+	if !ok {
+		entry = &dictEntry{path: path}
+		dicts.entries[path] = entry
+	}
+	return entry
+}
@@ -0,0 +1,86 @@
+package locate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPatternFile(t *testing.T) {
+	skipWithoutPatterns(t)
+	for loc, expected := range map[string]string{
+		"de-DE":      "hyph-de-1996.tex",
+		"de-CH":      "hyph-de-1996.tex", // current orthography
+		"de-CH-1901": "hyph-de-1996.tex", // fallback, traditional patterns are not installed
+		"en_GB":      "hyph-en-us.tex",   // fallback
+		"en_EN":      "hyph-en-us.tex",   // unknown region
+		"en":         "hyph-en-us.tex",
+	} {
+		path, err := PatternFile(loc)
+		if err != nil {
+			t.Errorf("expected pattern file for %s, got error: %v", loc, err)
+		} else if filepath.Base(path) != expected {
+			t.Errorf("expected %s for %s, is %s", expected, loc, path)
+		}
+	}
+	if _, err := PatternFile("ja"); err == nil {
+		t.Errorf("expected no pattern file for Japanese")
+	}
+}
+
+func TestPatternCandidates(t *testing.T) {
+	for loc, expected := range map[string]string{
+		"de":         "hyph-de-1996.tex",
+		"de-CH":      "hyph-de-1996.tex",
+		"de-CH-1996": "hyph-de-1996.tex",
+		"de-CH-1901": "hyph-de-ch-1901.tex hyph-de-1901.tex hyph-de-1996.tex",
+		"de-AT-1901": "hyph-de-1901.tex hyph-de-1996.tex",
+		"en-GB":      "hyph-en-gb.tex hyph-en-us.tex",
+	} {
+		candidates, err := patternCandidates(loc)
+		if err != nil {
+			t.Errorf("expected pattern files for %s, got error: %v", loc, err)
+		} else if strings.Join(candidates, " ") != expected {
+			t.Errorf("expected %s for %s, are %v", expected, loc, candidates)
+		}
+	}
+}
+
+func TestDictionaryCache(t *testing.T) {
+	skipWithoutPatterns(t)
+	var wg sync.WaitGroup
+	results := make([]interface{}, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dict, err := Dictionary([]string{"de-DE", "de-AT", "de", "de_CH"}[i])
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = dict
+		}(i)
+	}
+	wg.Wait()
+	for _, dict := range results[1:] {
+		if dict != results[0] {
+			t.Errorf("expected German dictionaries to be shared")
+		}
+	}
+	if _, err := Dictionary("ja"); err == nil {
+		t.Errorf("expected no dictionary for Japanese")
+	}
+	if _, err := Dictionary("ja"); err == nil {
+		t.Errorf("expected cached lookup to report missing dictionary for Japanese")
+	}
+}
+
+// skipWithoutPatterns skips a test if no pattern files are installed, i.e.
+// GTROOT is not set up.
+func skipWithoutPatterns(t *testing.T) {
+	if _, err := os.Stat(FileResource("", "pattern")); err != nil {
+		t.Skipf("no hyphenation patterns installed: %v", err)
+	}
+}
@@ -16,11 +16,8 @@ https://www.freedesktop.org/wiki/Software/fontconfig/
 package locate
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
-
-	"github.com/npillmayer/gotype/core/hyphenation"
 )
 
 func gtrootdir() string {
@@ -51,20 +48,3 @@ func FileResource(item string, typ string) string {
 	}
 	return path
 }
-
-var dicts map[string]*hyphenation.Dictionary
-
-func Dictionary(loc string) *hyphenation.Dictionary {
-	if dicts == nil {
-		dicts = make(map[string]*hyphenation.Dictionary)
-	}
-	if dicts[loc] == nil {
-		pname := "hyph-en-us.tex"
-		d := hyphenation.LoadPatterns(FileResource(pname, "pattern"))
-		dicts[loc] = d
-	}
-	if dicts[loc] == nil {
-		panic(fmt.Sprintf("No dictionnary found for %s", loc))
-	}
-	return dicts[loc]
-}
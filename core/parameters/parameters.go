@@ -34,7 +34,7 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. */
 package parameters
 
 import (
-	"golang.org/x/text/unicode/bidi"
+	"fmt"
 
 	"github.com/npillmayer/gotype/core/dimen"
 )
@@ -53,106 +53,164 @@ const (
 	P_HYPHENCHAR
 	P_HYPHENPENALTY
 	P_MINHYPHENLENGTH
+	P_CLUBPENALTY
+	P_WIDOWPENALTY
+	P_FRENCHSPACING
+	P_LEFTHYPHENMIN
+	P_RIGHTHYPHENMIN
+	P_HYPHENATEDIGITS
+	P_HYPHENATEUPPERCASE
+	P_HYPHENATEURLS
+	P_WORDSPACING
+	P_LETTERSPACING
+	P_TEXTALIGN
 	P_STOPPER
 )
 
-type ParameterGroup struct {
-	params map[TypesettingParameter]interface{}
-	level  int
-	next   *ParameterGroup
-}
-
+// TypesettingRegisters hold the values of typesetting parameters, with
+// TeX-like grouping: values changed within a group are restored at the end of
+// the group.
 type TypesettingRegisters struct {
-	base       [P_STOPPER]interface{}
-	groups     *ParameterGroup
-	grouplevel int
+	values []interface{} // current values, indexed by parameter key
+	saved  []savedValues // save stack, one entry per open group
+	shared bool          // values are shared with a snapshot, copy before writing
 }
 
+// savedValues holds the values of parameters at the start of a group, for every
+// parameter changed within the group.
+type savedValues map[TypesettingParameter]interface{}
+
 // ----------------------------------------------------------------------
 
+// NewTypesettingRegisters creates a set of registers, initialized with the
+// default values of all registered parameters.
 func NewTypesettingRegisters() *TypesettingRegisters {
 	regs := &TypesettingRegisters{}
-	initParameters(&regs.base)
+	registry.RLock()
+	defer registry.RUnlock()
+	regs.values = make([]interface{}, len(registry.params))
+	for key, p := range registry.params {
+		regs.values[key] = p.initial
+	}
 	return regs
 }
 
-func initParameters(p *[P_STOPPER]interface{}) {
-	p[P_LANGUAGE] = "en_EN"               // a string
-	p[P_SCRIPT] = "Latin"                 // a string
-	p[P_TEXTDIRECTION] = bidi.LeftToRight //
-	p[P_BASELINESKIP] = 12 * dimen.PT     // dimension
-	p[P_LINESKIP] = 0                     // dimension
-	p[P_LINESKIPLIMIT] = 0                // dimension
-	p[P_HYPHENCHAR] = int('-')            // a rune
-	p[P_HYPHENPENALTY] = 0                // a numeric penalty (int)
-	p[P_MINHYPHENLENGTH] = dimen.Infty    // a numeric quantitiv (int) = # of runes
-}
-
+// Begingroup opens a group. Values of parameters changed within the group
+// will be restored by the matching call to Endgroup.
 func (regs *TypesettingRegisters) Begingroup() {
-	regs.grouplevel++
+	regs.saved = append(regs.saved, nil) // allocate saved values lazily
 }
 
+// Endgroup closes a group, restoring all values changed within the group.
+// Calls to Endgroup without an open group are ignored.
 func (regs *TypesettingRegisters) Endgroup() {
-	if regs.grouplevel > 0 {
-		if regs.groups != nil && regs.groups.level == regs.grouplevel {
-			regs.groups = regs.groups.next
-			regs.grouplevel--
-		}
+	if len(regs.saved) == 0 {
+		return
 	}
+	top := regs.saved[len(regs.saved)-1]
+	if len(top) > 0 {
+		regs.own()
+	}
+	for key, value := range top {
+		regs.values[key] = value
+	}
+	regs.saved = regs.saved[:len(regs.saved)-1]
+}
+
+// GroupLevel returns the nesting level of groups, 0 being the outermost level.
+func (regs *TypesettingRegisters) GroupLevel() int {
+	return len(regs.saved)
 }
 
-func (regs *TypesettingRegisters) Push(key TypesettingParameter, value interface{}) {
-	if regs.grouplevel > 0 {
-		var g *ParameterGroup
-		if regs.groups == nil {
-			g = &ParameterGroup{}
-			g.params = make(map[TypesettingParameter]interface{})
-			g.level = regs.grouplevel
-			regs.groups = g
-		} else {
-			if regs.groups.level < regs.grouplevel {
-				g = &ParameterGroup{}
-				g.params = make(map[TypesettingParameter]interface{})
-				g.level = regs.grouplevel
-				g.next = regs.groups
-				regs.groups = g
-			} else {
-				g = regs.groups
-			}
+// Push sets the value of a parameter. Within a group, the value will be
+// restored at the end of the group.
+//
+// Returns an error if key is not a registered parameter or if value is of
+// the wrong type for the parameter. Integer parameters accept all integer
+// types except uintptr, including runes and bytes, if the value fits into an int.
+func (regs *TypesettingRegisters) Push(key TypesettingParameter, value interface{}) error {
+	p, ok := lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown typesetting parameter %d", key)
+	}
+	value, err := p.check(value)
+	if err != nil {
+		return err
+	}
+	regs.own()
+	regs.grow(key)
+	if len(regs.saved) > 0 {
+		top := regs.saved[len(regs.saved)-1]
+		if top == nil {
+			top = make(savedValues)
+			regs.saved[len(regs.saved)-1] = top
+		}
+		if _, saved := top[key]; !saved { // save the value only once per group
+			top[key] = regs.values[key]
 		}
-		g.params[key] = value
-	} else {
-		regs.base[key] = value
 	}
+	regs.values[key] = value
+	return nil
 }
 
-func (regs *TypesettingRegisters) Get(key TypesettingParameter) interface{} {
-	if key <= 0 || key == P_STOPPER {
-		panic("parameter key outside range of typesetting parameters")
+// own makes sure the values of the registers are not shared with a snapshot
+// before they are changed.
+func (regs *TypesettingRegisters) own() {
+	if regs.shared {
+		regs.values = append([]interface{}(nil), regs.values...)
+		regs.shared = false
 	}
-	var value interface{}
-	if regs.grouplevel > 0 {
-		for g := regs.groups; g != nil; g = g.next {
-			value = g.params[key]
-			if value != nil {
-				break
-			}
-		}
+}
+
+// grow makes room for parameters registered after the registers have been created.
+func (regs *TypesettingRegisters) grow(key TypesettingParameter) {
+	for int(key) >= len(regs.values) {
+		p, _ := lookup(TypesettingParameter(len(regs.values)))
+		regs.values = append(regs.values, p.initial)
 	}
-	if value == nil {
-		value = regs.base[key]
+}
+
+// Get returns the value of a parameter, or nil if key is not a registered
+// parameter.
+func (regs *TypesettingRegisters) Get(key TypesettingParameter) interface{} {
+	if key > 0 && int(key) < len(regs.values) {
+		return regs.values[key]
+	}
+	if p, ok := lookup(key); ok { // registered after the registers have been created
+		return p.initial
 	}
-	return value
+	return nil
 }
 
+// S returns the value of a string parameter, or "" if key is not a string parameter.
 func (regs *TypesettingRegisters) S(key TypesettingParameter) string {
-	return regs.Get(key).(string)
+	s, _ := regs.Get(key).(string)
+	return s
 }
 
+// N returns the value of an integer parameter, or 0 if key is not an integer
+// parameter.
 func (regs *TypesettingRegisters) N(key TypesettingParameter) int {
-	return regs.Get(key).(int)
+	n, _ := regs.Get(key).(int)
+	return n
 }
 
+// D returns the value of a dimension parameter, or 0 if key is not a dimension
+// parameter.
 func (regs *TypesettingRegisters) D(key TypesettingParameter) dimen.Dimen {
-	return regs.Get(key).(dimen.Dimen)
+	d, _ := regs.Get(key).(dimen.Dimen)
+	return d
+}
+
+// G returns the value of a glue parameter, or zero glue if key is not a glue
+// parameter.
+func (regs *TypesettingRegisters) G(key TypesettingParameter) Glue {
+	g, _ := regs.Get(key).(Glue)
+	return g
+}
+
+// B returns the value of a flag parameter, or false if key is not a flag parameter.
+func (regs *TypesettingRegisters) B(key TypesettingParameter) bool {
+	b, _ := regs.Get(key).(bool)
+	return b
 }
@@ -1,10 +1,14 @@
 package parameters
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
 
 func TestRegistersCreate(t *testing.T) {
 	regs := NewTypesettingRegisters()
-	if regs.base[P_LANGUAGE] != "en_EN" {
+	if regs.values[P_LANGUAGE] != "en_EN" {
 		t.Fail()
 	}
 	if lang := regs.S(P_LANGUAGE); lang != "en_EN" {
@@ -15,11 +19,11 @@ func TestRegistersCreate(t *testing.T) {
 func TestRegistersGrouping(t *testing.T) {
 	regs := NewTypesettingRegisters()
 	regs.Begingroup()
-	if regs.grouplevel != 1 {
+	if regs.GroupLevel() != 1 {
 		t.Fail()
 	}
 	regs.Push(P_LANGUAGE, "de_DE")
-	if regs.groups == nil {
+	if regs.saved[0] == nil {
 		t.Fail()
 	}
 	if regs.S(P_LANGUAGE) != "de_DE" {
@@ -36,3 +40,102 @@ func TestRegistersGrouping2(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestRegistersNestedGroups(t *testing.T) {
+	regs := NewTypesettingRegisters()
+	regs.Push(P_HYPHENPENALTY, 50)
+	regs.Begingroup()
+	regs.Push(P_HYPHENPENALTY, 100)
+	regs.Push(P_LANGUAGE, "de_DE")
+	regs.Begingroup() // no changes at this level
+	regs.Begingroup()
+	regs.Push(P_HYPHENPENALTY, 200)
+	regs.Push(P_HYPHENPENALTY, 300)
+	regs.Endgroup()
+	regs.Endgroup()
+	if regs.N(P_HYPHENPENALTY) != 100 || regs.S(P_LANGUAGE) != "de_DE" {
+		t.Errorf("expected values of level 1 to be restored, have %d and %q",
+			regs.N(P_HYPHENPENALTY), regs.S(P_LANGUAGE))
+	}
+	regs.Endgroup()
+	if regs.N(P_HYPHENPENALTY) != 50 || regs.S(P_LANGUAGE) != "en_EN" || regs.GroupLevel() != 0 {
+		t.Errorf("expected values of level 0 to be restored, have %d and %q",
+			regs.N(P_HYPHENPENALTY), regs.S(P_LANGUAGE))
+	}
+	regs.Endgroup() // ignored
+	if regs.N(P_HYPHENPENALTY) != 50 {
+		t.Errorf("expected superfluous Endgroup to be ignored")
+	}
+}
+
+func TestRegistersTypes(t *testing.T) {
+	regs := NewTypesettingRegisters()
+	if err := regs.Push(P_BASELINESKIP, "12pt"); err == nil {
+		t.Errorf("expected error for string value of dimension parameter")
+	}
+	if err := regs.Push(P_HYPHENCHAR, '‐'); err != nil || regs.N(P_HYPHENCHAR) != '‐' {
+		t.Errorf("expected rune to be accepted for integer parameter, error is %v", err)
+	}
+	for _, n := range []interface{}{uint(50), uint32(50), uint64(50)} {
+		if err := regs.Push(P_HYPHENPENALTY, n); err != nil || regs.N(P_HYPHENPENALTY) != 50 {
+			t.Errorf("expected %T to be accepted for integer parameter, error is %v", n, err)
+		}
+	}
+	if err := regs.Push(P_HYPHENPENALTY, ^uint64(0)); err == nil {
+		t.Errorf("expected error for integer value overflowing int")
+	}
+	if err := regs.Push(P_HYPHENPENALTY, dimen.BP); err == nil {
+		t.Errorf("expected error for dimension value of integer parameter")
+	}
+	if regs.S(P_BASELINESKIP) != "" {
+		t.Errorf("expected S to return empty string for dimension parameter")
+	}
+	parskip, err := RegisterParameter("parskip", GlueType, Glue{W: 6 * dimen.PT, Stretch: dimen.PT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RegisterParameter("parskip", IntegerType, 0); err == nil {
+		t.Errorf("expected error for duplicate parameter name")
+	}
+	if key, ok := ParameterByName("parskip"); !ok || key != parskip || key.Type() != GlueType {
+		t.Errorf("expected parskip to be registered as glue parameter")
+	}
+	if regs.G(parskip).W != 6*dimen.PT { // registers created before parameter
+		t.Errorf("expected default value for parskip, have %v", regs.G(parskip))
+	}
+	regs.Begingroup()
+	if err = regs.Push(parskip, Glue{}); err != nil || regs.G(parskip).W != 0 {
+		t.Errorf("expected parskip to be set to zero glue, error is %v", err)
+	}
+	regs.Endgroup()
+	if regs.G(parskip).W != 6*dimen.PT {
+		t.Errorf("expected parskip to be restored, have %v", regs.G(parskip))
+	}
+	if err = regs.Push(P_STOPPER, 0); err == nil {
+		t.Errorf("expected error for unregistered parameter")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	regs := NewTypesettingRegisters()
+	s1 := regs.Snapshot()
+	regs.Begingroup()
+	regs.Push(P_LANGUAGE, "de_DE")
+	regs.Push(P_HYPHENPENALTY, 100)
+	s2 := regs.Snapshot()
+	regs.Push(P_HYPHENPENALTY, 200)
+	regs.Endgroup()
+	if s1.S(P_LANGUAGE) != "en_EN" || s2.S(P_LANGUAGE) != "de_DE" || s2.N(P_HYPHENPENALTY) != 100 {
+		t.Errorf("expected snapshots to be immutable")
+	}
+	changes := Diff(s1, s2)
+	if len(changes) != 2 || changes[0].Key != P_LANGUAGE || changes[1].New != 100 {
+		t.Errorf("expected changes of language and hyphen penalty, have %v", changes)
+	}
+	if changes := Diff(s1, regs.Snapshot()); len(changes) != 0 {
+		t.Errorf("expected no changes after end of group, have %v", changes)
+	}
+	if (Snapshot{}).N(P_RIGHTHYPHENMIN) != 3 {
+		t.Errorf("expected zero snapshot to hold default values")
+	}
+}
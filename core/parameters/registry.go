@@ -0,0 +1,219 @@
+package parameters
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// RegisterType is the type of values a typesetting parameter holds.
+type RegisterType uint8
+
+// Types of typesetting parameters
+const (
+	NoType      RegisterType = iota
+	IntegerType              // int, e.g. a penalty
+	DimenType                // dimen.Dimen
+	GlueType                 // Glue
+	StringType               // string
+	FlagType                 // bool
+	TokenType                // a value of a fixed Go type, e.g. bidi.Direction
+)
+
+func (t RegisterType) String() string {
+	switch t {
+	case IntegerType:
+		return "integer"
+	case DimenType:
+		return "dimension"
+	case GlueType:
+		return "glue"
+	case StringType:
+		return "string"
+	case FlagType:
+		return "flag"
+	case TokenType:
+		return "token"
+	}
+	return "untyped"
+}
+
+// Glue is a dimension which may shrink and stretch.
+type Glue struct {
+	W, Shrink, Stretch dimen.Dimen
+}
+
+// parameter is the definition of a typesetting parameter.
+type parameter struct {
+	name    string
+	typ     RegisterType
+	token   reflect.Type // Go type of token parameters
+	initial interface{}  // default value
+}
+
+var registry = struct {
+	sync.RWMutex
+	params []parameter                     // indexed by key
+	names  map[string]TypesettingParameter // name => key
+}{names: make(map[string]TypesettingParameter)}
+
+func init() {
+	registry.params = make([]parameter, P_STOPPER+1) // none and P_STOPPER stay undefined
+	predefine(P_LANGUAGE, StringType, "en_EN")
+	predefine(P_SCRIPT, StringType, "Latin")
+	predefine(P_TEXTDIRECTION, TokenType, bidi.LeftToRight)
+	predefine(P_BASELINESKIP, DimenType, 12*dimen.PT)
+	predefine(P_LINESKIP, DimenType, dimen.Dimen(0))
+	predefine(P_LINESKIPLIMIT, DimenType, dimen.Dimen(0))
+	predefine(P_HYPHENCHAR, IntegerType, int('-'))         // a rune
+	predefine(P_HYPHENPENALTY, IntegerType, 0)             // a numeric penalty
+	predefine(P_MINHYPHENLENGTH, IntegerType, dimen.Infty) // # of runes
+	predefine(P_CLUBPENALTY, IntegerType, 150)             // penalty for a break after the first line of a paragraph
+	predefine(P_WIDOWPENALTY, IntegerType, 150)            // penalty for a break before the last line of a paragraph
+	predefine(P_FRENCHSPACING, FlagType, false)            // no extra space after sentences
+	predefine(P_LEFTHYPHENMIN, IntegerType, 2)             // minimum # of runes before a hyphen
+	predefine(P_RIGHTHYPHENMIN, IntegerType, 3)            // minimum # of runes after a hyphen
+	predefine(P_HYPHENATEDIGITS, FlagType, false)          // hyphenate words containing digits
+	predefine(P_HYPHENATEUPPERCASE, FlagType, false)       // hyphenate words in uppercase, e.g. acronyms
+	predefine(P_HYPHENATEURLS, FlagType, false)            // hyphenate URLs and e-mail addresses
+	predefine(P_WORDSPACING, DimenType, dimen.Dimen(0))    // additional space between words
+	predefine(P_LETTERSPACING, DimenType, dimen.Dimen(0))  // additional space between characters
+	predefine(P_TEXTALIGN, StringType, "justify")          // alignment of lines, as CSS text-align
+}
+
+func predefine(key TypesettingParameter, typ RegisterType, initial interface{}) {
+	registry.params[key] = parameter{
+		name:    key.String(),
+		typ:     typ,
+		token:   reflect.TypeOf(initial),
+		initial: initial,
+	}
+	registry.names[key.String()] = key
+}
+
+// RegisterParameter registers a new typesetting parameter with a unique name,
+// a type and a default value. For parameters of type TokenType, all values
+// must be of the Go type of the default value.
+//
+// Returns the key of the new parameter, or an error if the name is already in
+// use or the default value is not of the given type.
+func RegisterParameter(name string, typ RegisterType, initial interface{}) (TypesettingParameter, error) {
+	if typ == NoType || typ > TokenType {
+		return none, fmt.Errorf("cannot register parameter %q with invalid type", name)
+	}
+	p := parameter{name: name, typ: typ, token: reflect.TypeOf(initial)}
+	if typ == TokenType && initial == nil {
+		return none, fmt.Errorf("token parameter %q needs a default value", name)
+	}
+	var err error
+	if p.initial, err = p.check(initial); err != nil {
+		return none, err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.names[name]; exists {
+		return none, fmt.Errorf("typesetting parameter %q already registered", name)
+	}
+	key := TypesettingParameter(len(registry.params))
+	registry.params = append(registry.params, p)
+	registry.names[name] = key
+	return key, nil
+}
+
+// ParameterByName returns the key of a typesetting parameter. Predefined
+// parameters are named like their keys, e.g. "P_LANGUAGE".
+func ParameterByName(name string) (TypesettingParameter, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	key, ok := registry.names[name]
+	return key, ok
+}
+
+// Name returns the name of a typesetting parameter.
+func (key TypesettingParameter) Name() string {
+	if p, ok := lookup(key); ok {
+		return p.name
+	}
+	return key.String()
+}
+
+// Type returns the type of a typesetting parameter, or NoType for an
+// unregistered key.
+func (key TypesettingParameter) Type() RegisterType {
+	p, _ := lookup(key)
+	return p.typ
+}
+
+func lookup(key TypesettingParameter) (parameter, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if key <= none || int(key) >= len(registry.params) || registry.params[key].typ == NoType {
+		return parameter{}, false
+	}
+	return registry.params[key], true
+}
+
+// Range of type int on the target platform.
+const (
+	maxInt = int64(^uint(0) >> 1)
+	minInt = -maxInt - 1
+)
+
+// check checks if a value is of the type of the parameter, converting integer
+// types to int. Integer parameters accept all signed and unsigned integer types
+// except uintptr, as long as the value fits into an int.
+// Returns the (converted) value.
+func (p parameter) check(value interface{}) (interface{}, error) {
+	ok := false
+	switch p.typ {
+	case IntegerType:
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case int8:
+			return int(n), nil
+		case int16:
+			return int(n), nil
+		case int32: // includes runes
+			return int(n), nil
+		case int64:
+			if n >= minInt && n <= maxInt {
+				return int(n), nil
+			}
+		case uint8: // includes bytes
+			return int(n), nil
+		case uint16:
+			return int(n), nil
+		case uint32:
+			if int64(n) <= maxInt {
+				return int(n), nil
+			}
+		case uint:
+			if uint64(n) <= uint64(maxInt) {
+				return int(n), nil
+			}
+		case uint64:
+			if n <= uint64(maxInt) {
+				return int(n), nil
+			}
+		}
+	case DimenType:
+		_, ok = value.(dimen.Dimen)
+	case GlueType:
+		_, ok = value.(Glue)
+	case StringType:
+		_, ok = value.(string)
+	case FlagType:
+		_, ok = value.(bool)
+	case TokenType:
+		ok = value != nil && reflect.TypeOf(value) == p.token
+	}
+	if !ok {
+		return nil, fmt.Errorf("typesetting parameter %s is of type %s, cannot set to %v (%T)",
+			p.name, p.typ, value, value)
+	}
+	return value, nil
+}
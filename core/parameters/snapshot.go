@@ -0,0 +1,95 @@
+package parameters
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// A Snapshot is an immutable copy of the state of typesetting registers, e.g.,
+// to remember the registers in effect for a paragraph. Taking a snapshot is
+// cheap: snapshots share the values with the registers, which will copy them
+// on the next change (copy-on-write).
+//
+// The zero value of a snapshot holds the default values of all parameters.
+type Snapshot struct {
+	values []interface{}
+}
+
+// Snapshot returns the current state of the registers.
+func (regs *TypesettingRegisters) Snapshot() Snapshot {
+	regs.shared = true
+	return Snapshot{values: regs.values}
+}
+
+// Get returns the value of a parameter, or nil if key is not a registered
+// parameter.
+func (s Snapshot) Get(key TypesettingParameter) interface{} {
+	if key > 0 && int(key) < len(s.values) {
+		return s.values[key]
+	}
+	if p, ok := lookup(key); ok { // registered after the snapshot has been taken
+		return p.initial
+	}
+	return nil
+}
+
+// S returns the value of a string parameter, or "" if key is not a string parameter.
+func (s Snapshot) S(key TypesettingParameter) string {
+	str, _ := s.Get(key).(string)
+	return str
+}
+
+// N returns the value of an integer parameter, or 0 if key is not an integer
+// parameter.
+func (s Snapshot) N(key TypesettingParameter) int {
+	n, _ := s.Get(key).(int)
+	return n
+}
+
+// D returns the value of a dimension parameter, or 0 if key is not a dimension
+// parameter.
+func (s Snapshot) D(key TypesettingParameter) dimen.Dimen {
+	d, _ := s.Get(key).(dimen.Dimen)
+	return d
+}
+
+// G returns the value of a glue parameter, or zero glue if key is not a glue
+// parameter.
+func (s Snapshot) G(key TypesettingParameter) Glue {
+	g, _ := s.Get(key).(Glue)
+	return g
+}
+
+// B returns the value of a flag parameter, or false if key is not a flag parameter.
+func (s Snapshot) B(key TypesettingParameter) bool {
+	b, _ := s.Get(key).(bool)
+	return b
+}
+
+// Change is the difference of a parameter between two snapshots.
+type Change struct {
+	Key      TypesettingParameter
+	Old, New interface{}
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v → %v", c.Key.Name(), c.Old, c.New)
+}
+
+// Diff returns the parameters which differ between two snapshots, ordered
+// by key.
+func Diff(old, new Snapshot) []Change {
+	registry.RLock()
+	n := len(registry.params)
+	registry.RUnlock()
+	var changes []Change
+	for key := TypesettingParameter(1); int(key) < n; key++ {
+		o, v := old.Get(key), new.Get(key)
+		if !reflect.DeepEqual(o, v) { // token values may not be comparable
+			changes = append(changes, Change{Key: key, Old: o, New: v})
+		}
+	}
+	return changes
+}
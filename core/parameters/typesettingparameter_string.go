@@ -4,9 +4,9 @@ package parameters
 
 import "strconv"
 
-const _TypesettingParameter_name = "noneP_LANGUAGEP_SCRIPTP_TEXTDIRECTIONP_BASELINESKIPP_LINESKIPP_LINESKIPLIMITP_HYPHENPENALTYP_STOPPER"
+const _TypesettingParameter_name = "noneP_LANGUAGEP_SCRIPTP_TEXTDIRECTIONP_BASELINESKIPP_LINESKIPP_LINESKIPLIMITP_HYPHENCHARP_HYPHENPENALTYP_MINHYPHENLENGTHP_CLUBPENALTYP_WIDOWPENALTYP_FRENCHSPACINGP_LEFTHYPHENMINP_RIGHTHYPHENMINP_HYPHENATEDIGITSP_HYPHENATEUPPERCASEP_HYPHENATEURLSP_WORDSPACINGP_LETTERSPACINGP_TEXTALIGNP_STOPPER"
 
-var _TypesettingParameter_index = [...]uint8{0, 4, 14, 22, 37, 51, 61, 76, 91, 100}
+var _TypesettingParameter_index = [...]uint16{0, 4, 14, 22, 37, 51, 61, 76, 88, 103, 120, 133, 147, 162, 177, 193, 210, 230, 245, 258, 273, 284, 293}
 
 func (i TypesettingParameter) String() string {
 	if i < 0 || i >= TypesettingParameter(len(_TypesettingParameter_index)-1) {
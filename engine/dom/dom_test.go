@@ -8,10 +8,13 @@ import (
 	"github.com/npillmayer/gotype/core/config/gtrace"
 	"github.com/npillmayer/gotype/core/config/tracing"
 	"github.com/npillmayer/gotype/core/config/tracing/gotestingadapter"
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/core/parameters"
 	"github.com/npillmayer/gotype/engine/dom"
 	"github.com/npillmayer/gotype/engine/dom/domdbg"
 	"github.com/npillmayer/gotype/engine/tree"
 	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/bidi"
 )
 
 var graphviz = false
@@ -103,6 +106,38 @@ func TestW3CStyles1(t *testing.T) {
 	}
 }
 
+func TestTypesettingRegisters(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	h, err := html.Parse(strings.NewReader(`<html lang="de-CH"><body>
+  <p style="line-height: 15pt; direction: rtl;">Text</p></body></html>`))
+	if err != nil {
+		t.Fatalf("Cannot create test document")
+	}
+	root := dom.FromHTMLParseTree(h, nil)
+	p := root.FirstChild().FirstChild().NextSibling().FirstChild()
+	for p != nil && p.NodeName() != "p" {
+		p = p.NextSibling()
+	}
+	regs := parameters.NewTypesettingRegisters()
+	if err = dom.PushTypesettingRegisters(p, regs); err != nil {
+		t.Fatal(err)
+	}
+	if lang := regs.S(parameters.P_LANGUAGE); lang != "de-CH" {
+		t.Errorf("expected language to be inherited from <html>, is %s", lang)
+	}
+	if bskip := regs.D(parameters.P_BASELINESKIP); bskip != 15*dimen.BP {
+		t.Errorf("expected baselineskip of 15pt, is %.2fbp", bskip.Points())
+	}
+	if regs.Get(parameters.P_TEXTDIRECTION) != bidi.RightToLeft {
+		t.Errorf("expected text direction to be right-to-left")
+	}
+	regs.Endgroup()
+	if regs.S(parameters.P_LANGUAGE) != "en_EN" {
+		t.Errorf("expected registers to be restored after end of group")
+	}
+}
+
 /*
 func prepareStyledTree(t *testing.T) *tree.Node {
 	h, errhtml := html.Parse(strings.NewReader(myhtml))
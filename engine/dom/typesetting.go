@@ -0,0 +1,172 @@
+package dom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	params "github.com/npillmayer/gotype/core/parameters"
+	"github.com/npillmayer/gotype/engine/dom/cssom/style"
+	"github.com/npillmayer/gotype/engine/dom/w3cdom"
+	"golang.org/x/net/html"
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Typesetting parameters from CSS:
+//
+//     hyphens          P_MINHYPHENLENGTH ("none" and "manual" switch off hyphenation
+//                      by patterns, soft hyphens are always respected)
+//     lang attribute   P_LANGUAGE
+//     line-height      P_BASELINESKIP
+//     word-spacing     P_WORDSPACING
+//     letter-spacing   P_LETTERSPACING
+//     text-align       P_TEXTALIGN
+//     direction        P_TEXTDIRECTION
+//
+// Properties without a value, or with value "inherit", leave the corresponding
+// register unchanged. Value "initial" resets a register to its default value.
+
+// defaultFontSize is the size of CSS font-size "medium".
+const defaultFontSize = 12 * dimen.BP
+
+// PushTypesettingRegisters opens a group in regs and sets typesetting parameters
+// from the computed styles of a DOM node. Clients will typeset the text of the
+// node with these registers and then close the group with regs.Endgroup().
+//
+// Returns an error if a property has an invalid value. All valid properties
+// will be set nevertheless.
+func PushTypesettingRegisters(node w3cdom.Node, regs *params.TypesettingRegisters) error {
+	regs.Begingroup()
+	if node == nil {
+		return nil
+	}
+	styles := node.ComputedStyles()
+	if styles == nil {
+		return nil
+	}
+	var errs []string
+	push := func(key params.TypesettingParameter, value interface{}, err error) {
+		if err == nil {
+			err = regs.Push(key, value)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	fontsize := defaultFontSize
+	if p := styles.GetPropertyValue("font-size"); isSet(p) {
+		if fs, err := cssLength(p, defaultFontSize, defaultFontSize); err == nil {
+			fontsize = fs
+		}
+	}
+	if p := styles.GetPropertyValue("hyphens"); isSet(p) {
+		switch p {
+		case "initial", "none", "manual":
+			push(params.P_MINHYPHENLENGTH, dimen.Infty, nil)
+		case "auto":
+			minlen := regs.N(params.P_LEFTHYPHENMIN) + regs.N(params.P_RIGHTHYPHENMIN)
+			push(params.P_MINHYPHENLENGTH, minlen, nil)
+		default:
+			push(params.P_MINHYPHENLENGTH, nil, invalid("hyphens", p))
+		}
+	}
+	if lang := language(node); lang != "" {
+		push(params.P_LANGUAGE, lang, nil)
+	}
+	if p := styles.GetPropertyValue("line-height"); isSet(p) {
+		switch p {
+		case "initial", "normal":
+			push(params.P_BASELINESKIP, fontsize*6/5, nil)
+		default:
+			if f, err := strconv.ParseFloat(string(p), 64); err == nil { // factor of font size
+				push(params.P_BASELINESKIP, dimen.Dimen(f*float64(fontsize)), nil)
+			} else {
+				lh, err := cssLength(p, fontsize, fontsize)
+				push(params.P_BASELINESKIP, lh, err)
+			}
+		}
+	}
+	for _, spacing := range []struct {
+		property string
+		key      params.TypesettingParameter
+	}{
+		{"word-spacing", params.P_WORDSPACING},
+		{"letter-spacing", params.P_LETTERSPACING},
+	} {
+		if p := styles.GetPropertyValue(spacing.property); isSet(p) {
+			if p == "initial" || p == "normal" {
+				push(spacing.key, dimen.Dimen(0), nil)
+			} else {
+				d, err := cssLength(p, fontsize, fontsize)
+				push(spacing.key, d, err)
+			}
+		}
+	}
+	if p := styles.GetPropertyValue("text-align"); isSet(p) {
+		switch p {
+		case "initial": // default of P_TEXTALIGN, deviating from CSS
+			push(params.P_TEXTALIGN, "justify", nil)
+		case "left", "right", "center", "justify", "start", "end":
+			push(params.P_TEXTALIGN, string(p), nil)
+		default:
+			push(params.P_TEXTALIGN, nil, invalid("text-align", p))
+		}
+	}
+	if p := styles.GetPropertyValue("direction"); isSet(p) {
+		switch p {
+		case "initial", "ltr":
+			push(params.P_TEXTDIRECTION, bidi.LeftToRight, nil)
+		case "rtl":
+			push(params.P_TEXTDIRECTION, bidi.RightToLeft, nil)
+		default:
+			push(params.P_TEXTDIRECTION, nil, invalid("direction", p))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot set typesetting parameters: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func isSet(p style.Property) bool {
+	return !p.IsEmpty() && p != "inherit"
+}
+
+func invalid(property string, p style.Property) error {
+	return fmt.Errorf("invalid value for %s: %q", property, p)
+}
+
+// language finds the language of a node from its lang attribute or the lang
+// attribute of the nearest ancestor which has one.
+func language(node w3cdom.Node) string {
+	for n := node; n != nil; n = n.ParentNode() {
+		if n.NodeType() != html.ElementNode || !n.HasAttributes() {
+			continue
+		}
+		if attr := n.Attributes().GetNamedItem("lang"); attr != nil {
+			return attr.Value()
+		}
+	}
+	return ""
+}
+
+// cssLength converts a CSS length to a dimension. Lengths relative to the
+// font are calculated from em, percentages from base.
+func cssLength(p style.Property, em, base dimen.Dimen) (dimen.Dimen, error) {
+	ctx := dimen.Context{
+		Em: em,
+		Ex: em / 2,
+		Units: map[string]dimen.Dimen{
+			"pt":  dimen.BP, // CSS points are 1/72 inch
+			"pc":  12 * dimen.BP,
+			"rem": defaultFontSize,
+			"%":   base / 100,
+		},
+	}
+	d, err := ctx.Parse(string(p))
+	if err != nil {
+		return 0, fmt.Errorf("invalid CSS length %q", strings.TrimSpace(string(p)))
+	}
+	return d, nil
+}
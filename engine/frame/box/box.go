@@ -135,12 +135,7 @@ type Glyph struct {
 
 // Normalize sorts the corner coordinates into correct order.
 func (box *Box) Normalize() *Box {
-	if box.TopL.X > box.BotR.X {
-		box.TopL.X, box.BotR.X = box.BotR.X, box.TopL.X
-	}
-	if box.TopL.Y > box.BotR.Y {
-		box.TopL.Y, box.BotR.Y = box.BotR.Y, box.TopL.Y
-	}
+	box.Rect = box.Rect.Canon()
 	return box
 }
 
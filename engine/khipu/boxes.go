@@ -0,0 +1,294 @@
+package khipu
+
+import (
+	"fmt"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// Knots in this file have a fixed size, i.e. they neither shrink nor stretch.
+// Boxes, rules and images are part of horizontal lists, with W() returning their
+// width. Line-breakers treat them like text boxes.
+
+// --- HBox ------------------------------------------------------------------
+
+// An HBox is a box containing a horizontal list. The content is set to the
+// width of the box, which is fixed.
+type HBox struct {
+	Width   dimen.Dimen // width
+	Height  dimen.Dimen // height
+	Depth   dimen.Dimen // depth
+	Content *Khipu      // horizontal list
+}
+
+// NewHBox creates a box for a horizontal list, with the natural width of the
+// content.
+func NewHBox(content *Khipu) *HBox {
+	w, _, _ := content.Measure(0, -1)
+	return NewHBoxTo(content, w)
+}
+
+// NewHBoxTo creates a box for a horizontal list, with a given width.
+// The content will stretch or shrink to fit the width.
+func NewHBoxTo(content *Khipu, width dimen.Dimen) *HBox {
+	box := &HBox{Width: width, Content: content}
+	box.Height, box.Depth = content.MaxHeightAndDepth(0, content.Length())
+	return box
+}
+
+// Type is part of interface Knot.
+func (b *HBox) Type() KnotType {
+	return KTHBox
+}
+
+func (b *HBox) String() string {
+	return fmt.Sprintf("\\hbox to %.2f%s", b.Width.Points(), b.Content)
+}
+
+// W is part of interface Knot. Width of the box.
+func (b *HBox) W() dimen.Dimen {
+	return b.Width
+}
+
+// MinW is part of interface Knot. Width of the box.
+func (b *HBox) MinW() dimen.Dimen {
+	return b.Width
+}
+
+// MaxW is part of interface Knot. Width of the box.
+func (b *HBox) MaxW() dimen.Dimen {
+	return b.Width
+}
+
+// IsDiscardable is part of interface Knot. Boxes are not discardable.
+func (b *HBox) IsDiscardable() bool {
+	return false
+}
+
+// Pack sets the glue of the box's content to the width of the box and
+// returns the positioned content.
+func (b *HBox) Pack() *Line {
+	line := &Line{To: b.Content.Length(), Width: b.Width, Height: b.Height, Depth: b.Depth}
+	for _, knot := range b.Content.knots {
+		line.Knots = append(line.Knots, PositionedKnot{Knot: knot})
+	}
+	line.setGlue()
+	return line
+}
+
+var _ Knot = &HBox{}
+
+// --- VBox ------------------------------------------------------------------
+
+// A VBox is a box containing a vertical list. Its height is the natural height
+// of the content, its depth is the depth of the last line of the content.
+// Within a horizontal list, a VBox is aligned at the baseline of its last line.
+type VBox struct {
+	Width   dimen.Dimen // width
+	Height  dimen.Dimen // height
+	Depth   dimen.Dimen // depth
+	Content *Khipu      // vertical list
+}
+
+// NewVBox creates a box for a vertical list. The width of the box is the
+// maximum width of the lines in the content.
+func NewVBox(content *Khipu) *VBox {
+	box := &VBox{Content: content}
+	h, _, _ := content.Measure(0, -1)
+	for _, knot := range content.knots {
+		if line, ok := knot.(*Line); ok && line.Width > box.Width {
+			box.Width = line.Width
+		}
+	}
+	if d, ok := content.prevDepth(); ok {
+		box.Depth = d
+	}
+	box.Height = h - box.Depth
+	return box
+}
+
+// Type is part of interface Knot.
+func (b *VBox) Type() KnotType {
+	return KTVBox
+}
+
+func (b *VBox) String() string {
+	return fmt.Sprintf("\\vbox to %.2f%s", b.Height.Points(), b.Content)
+}
+
+// W is part of interface Knot. Width of the box.
+func (b *VBox) W() dimen.Dimen {
+	return b.Width
+}
+
+// MinW is part of interface Knot. Width of the box.
+func (b *VBox) MinW() dimen.Dimen {
+	return b.Width
+}
+
+// MaxW is part of interface Knot. Width of the box.
+func (b *VBox) MaxW() dimen.Dimen {
+	return b.Width
+}
+
+// IsDiscardable is part of interface Knot. Boxes are not discardable.
+func (b *VBox) IsDiscardable() bool {
+	return false
+}
+
+var _ Knot = &VBox{}
+
+// --- Rule ------------------------------------------------------------------
+
+// A Rule is a filled rectangle.
+type Rule struct {
+	Width  dimen.Dimen // width
+	Height dimen.Dimen // height above the baseline
+	Depth  dimen.Dimen // depth below the baseline
+}
+
+// NewRule creates a rule with given dimensions.
+func NewRule(w, h, d dimen.Dimen) Rule {
+	return Rule{Width: w, Height: h, Depth: d}
+}
+
+// Type is part of interface Knot.
+func (r Rule) Type() KnotType {
+	return KTRule
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("▮%.2fx%.2f", r.Width.Points(), (r.Height + r.Depth).Points())
+}
+
+// W is part of interface Knot. Width of the rule.
+func (r Rule) W() dimen.Dimen {
+	return r.Width
+}
+
+// MinW is part of interface Knot. Width of the rule.
+func (r Rule) MinW() dimen.Dimen {
+	return r.Width
+}
+
+// MaxW is part of interface Knot. Width of the rule.
+func (r Rule) MaxW() dimen.Dimen {
+	return r.Width
+}
+
+// IsDiscardable is part of interface Knot. Rules are not discardable.
+func (r Rule) IsDiscardable() bool {
+	return false
+}
+
+// --- Image -----------------------------------------------------------------
+
+// An Image is an inline image, sitting on the baseline.
+// Images are not loaded by the typesetter, but referenced by source, which is
+// interpreted by the renderer.
+type Image struct {
+	Width  dimen.Dimen // width
+	Height dimen.Dimen // height above the baseline
+	Depth  dimen.Dimen // depth below the baseline
+	Source string      // URL or file path of the image
+}
+
+// NewImage creates an inline image of a given size.
+func NewImage(source string, w, h dimen.Dimen) *Image {
+	return &Image{Width: w, Height: h, Source: source}
+}
+
+// Type is part of interface Knot.
+func (img *Image) Type() KnotType {
+	return KTImage
+}
+
+func (img *Image) String() string {
+	return fmt.Sprintf("▣%s", img.Source)
+}
+
+// W is part of interface Knot. Width of the image.
+func (img *Image) W() dimen.Dimen {
+	return img.Width
+}
+
+// MinW is part of interface Knot. Width of the image.
+func (img *Image) MinW() dimen.Dimen {
+	return img.Width
+}
+
+// MaxW is part of interface Knot. Width of the image.
+func (img *Image) MaxW() dimen.Dimen {
+	return img.Width
+}
+
+// IsDiscardable is part of interface Knot. Images are not discardable.
+func (img *Image) IsDiscardable() bool {
+	return false
+}
+
+var _ Knot = &Image{}
+
+// --- Whatsit ---------------------------------------------------------------
+
+// A Whatsit carries information through line- and page-breaking to the
+// renderer, e.g., link anchors, index entries or color changes.
+// Whatsits have no width and are neither interpreted by the typesetter nor
+// discarded at line breaks.
+type Whatsit struct {
+	Tag     string      // kind of payload, interpreted by clients
+	Payload interface{} // opaque payload
+}
+
+// NewWhatsit creates a whatsit knot for a payload.
+func NewWhatsit(tag string, payload interface{}) Whatsit {
+	return Whatsit{Tag: tag, Payload: payload}
+}
+
+// Type is part of interface Knot.
+func (w Whatsit) Type() KnotType {
+	return KTWhatsit
+}
+
+func (w Whatsit) String() string {
+	return fmt.Sprintf("⌘%s", w.Tag)
+}
+
+// W is part of interface Knot. Returns 0.
+func (w Whatsit) W() dimen.Dimen {
+	return 0
+}
+
+// MinW is part of interface Knot. Returns 0.
+func (w Whatsit) MinW() dimen.Dimen {
+	return 0
+}
+
+// MaxW is part of interface Knot. Returns 0.
+func (w Whatsit) MaxW() dimen.Dimen {
+	return 0
+}
+
+// IsDiscardable is part of interface Knot. Whatsits are not discardable.
+func (w Whatsit) IsDiscardable() bool {
+	return false
+}
+
+// ---------------------------------------------------------------------------
+
+// heightAndDepth returns the vertical dimensions of knots which have them.
+func heightAndDepth(knot Knot) (dimen.Dimen, dimen.Dimen, bool) {
+	switch k := knot.(type) {
+	case *TextBox:
+		return k.Height, k.Depth, true
+	case *HBox:
+		return k.Height, k.Depth, true
+	case *VBox:
+		return k.Height, k.Depth, true
+	case Rule:
+		return k.Height, k.Depth, true
+	case *Image:
+		return k.Height, k.Depth, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,299 @@
+package khipu
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// LineLengths is a type to return the line length for a given line number,
+// starting at 1. It is implemented by linebreak.ParShape.
+type LineLengths interface {
+	LineLength(int) dimen.Dimen
+}
+
+// LineIndents is a type to return the offset of a line from the left edge of
+// the paragraph, for a given line number starting at 1. If the line lengths
+// given to HPack implement it, lines will carry their indentation.
+// It is implemented by linebreak.ParShape.
+type LineIndents interface {
+	LineIndent(int) dimen.Dimen
+}
+
+// Protruder is a type to return the amount a knot at the start or at the end
+// of a line may protrude into the margin. It is implemented by
+// linebreak.Protrusion.
+type Protruder interface {
+	LeftProtrusion(Knot) dimen.Dimen
+	RightProtrusion(Knot) dimen.Dimen
+}
+
+// IsEdgeKnot returns true if a knot may be at the edge of a line, i.e. if it is
+// considered for margin protrusion. As in pdfTeX, discardable knots, whatsits,
+// inserts and knots without width are skipped when looking for the first and
+// last knot of a line. Discretionaries are edge knots, as their pre-break knots
+// end a line broken at them.
+func IsEdgeKnot(knot Knot) bool {
+	switch knot.Type() {
+	case KTDiscretionary:
+		return true
+	case KTWhatsit, KTInsert:
+		return false
+	}
+	return !knot.IsDiscardable() && knot.W() != 0
+}
+
+// Skips holds glue to insert at the edges of lines when packing them.
+// Lines should be packed with the margin protrusion and font expansion they
+// have been broken with, otherwise they may come out overfull or underfull.
+type Skips struct {
+	LeftSkip    Glue      // glue at left edge of every line
+	RightSkip   Glue      // glue at right edge of every line
+	ParFillSkip Glue      // glue at the end of the last line
+	Protrusion  Protruder // margin protrusion, may be nil
+	FontStretch int32     // font expansion of text boxes, in thousandths of their width
+	FontShrink  int32     // font contraction of text boxes, in thousandths of their width
+}
+
+// PositionedKnot is a knot within a packed line, together with its position.
+type PositionedKnot struct {
+	Knot Knot        // the knot
+	X    dimen.Dimen // offset from the start of the line
+	W    dimen.Dimen // width of the knot, after glue has been set
+}
+
+// Line is a line of a paragraph, with its glue set to fit the line's target
+// width. Lines are produced by HPack.
+type Line struct {
+	Knots    []PositionedKnot // knots of the line, including skips and hyphens
+	From, To int              // range [From…To) of the knots in the source khipu
+	Indent   dimen.Dimen      // offset of the line from the left edge of the paragraph
+	Width    dimen.Dimen      // target width of the line
+	Natural  dimen.Dimen      // natural width of the line's material
+	Height   dimen.Dimen      // maximum height of text in the line
+	Depth    dimen.Dimen      // maximum depth of text in the line
+	GlueSet  float64          // glue set ratio: > 0 for stretching, < 0 for shrinking
+	Order    int              // order of stretching glue: 0 = finite, 1 = fil, 2 = fill, 3 = filll
+	stretch  int32            // font expansion of text boxes, see Skips
+	shrink   int32            // font contraction of text boxes, see Skips
+}
+
+// GlueWidth returns the width of a glue after setting the line's glue ratio.
+// If the line contains infinitely stretchable glue, only glue of the line's order
+// will stretch.
+func (l *Line) GlueWidth(g Glue) dimen.Dimen {
+	if l.GlueSet > 0 {
+		if stretchOrder(g[2]) != l.Order {
+			return g[0]
+		}
+		return g[0] + dimen.Dimen(l.GlueSet*float64(g[2]))
+	} else if l.GlueSet < 0 {
+		return g[0] + dimen.Dimen(l.GlueSet*float64(g[1]))
+	}
+	return g[0]
+}
+
+// BoxWidth returns the width of a text box after setting the line's glue ratio.
+// With font expansion (see Skips), text boxes stretch and shrink together with
+// finite glue.
+func (l *Line) BoxWidth(box *TextBox) dimen.Dimen {
+	if l.GlueSet > 0 && l.Order == 0 {
+		return box.W() + dimen.Dimen(l.GlueSet*float64(box.W().MulRatio(int64(l.stretch), 1000)))
+	} else if l.GlueSet < 0 {
+		return box.W() + dimen.Dimen(l.GlueSet*float64(box.W().MulRatio(int64(l.shrink), 1000)))
+	}
+	return box.W()
+}
+
+func (l *Line) String() string {
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("\\hbox to %.2f (%.3f){", l.Width.Points(), l.GlueSet))
+	for i, pk := range l.Knots {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(KnotString(pk.Knot))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// HPack packs the knots of a paragraph into lines, given a set of breakpoints.
+// Breakpoints are usually the result of a line-breaking algorithm and include
+// the pseudo-breakpoint at the start of the paragraph (with position -1).
+//
+// For every line,
+//
+// - discardable knots at the start and at the end of the line are removed
+//
+// - penalties are removed
+//
+// - discretionaries are replaced by their no-break knots, except at a breakpoint,
+// where they are replaced by their pre-break knots (usually a text box containing
+// the hyphen character), with the post-break knots starting the next line
+//
+// - skips.LeftSkip and skips.RightSkip are inserted at the edges of the line,
+// with skips.ParFillSkip in front of RightSkip for the last line
+//
+// - if skips.Protrusion is set, negative kerns are inserted inside the skips to
+// let characters at the edges of the line protrude into the margins
+//
+// - if skips.FontStretch or skips.FontShrink are set, text boxes add to the
+// finite stretchability and shrinkability of the line
+//
+// - the glue set ratio is calculated and every knot is assigned an x-offset.
+//
+// As in TeX, infinitely stretchable glue (see NewFill) takes precedence over
+// finite stretchability: if present, no finite glue will stretch. For an overfull line, glue will not shrink below its minimum width.
+//
+// Lines broken by package linebreak should be packed with
+// linebreak.Parameters.Skips, which carries over protrusion and font expansion.
+func HPack(kh *Khipu, breakpoints []Mark, linelengths LineLengths, skips Skips) []*Line {
+	if kh == nil || linelengths == nil || len(breakpoints) < 2 {
+		return nil
+	}
+	lines := make([]*Line, 0, len(breakpoints)-1)
+	for i := 1; i < len(breakpoints); i++ {
+		from, to := breakpoints[i-1].Position()+1, breakpoints[i].Position()
+		line := kh.packLine(from, to, i == len(breakpoints)-1, skips)
+		line.Width = linelengths.LineLength(i)
+		if indents, ok := linelengths.(LineIndents); ok {
+			line.Indent = indents.LineIndent(i)
+		}
+		line.setGlue()
+		CT().Debugf("line %d = %s", i, line)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// packLine collects the knots in range [from…to) for a line, with the
+// breakpoint at index to.
+func (kh *Khipu) packLine(from, to int, final bool, skips Skips) *Line {
+	from, to = iMax(0, from), iMin(to, len(kh.knots))
+	var post []Knot // post-break knots of a discretionary at the previous breakpoint
+	if from > 0 && from <= len(kh.knots) {
+		if d, ok := kh.knots[from-1].(Discretionary); ok {
+			post = d.Post
+		}
+	}
+	for from < to && kh.knots[from].IsDiscardable() {
+		from++
+	}
+	end := to
+	for end > from && kh.knots[end-1].IsDiscardable() {
+		end--
+	}
+	line := &Line{From: from, To: end, stretch: skips.FontStretch, shrink: skips.FontShrink}
+	line.Knots = append(line.Knots, PositionedKnot{Knot: skips.LeftSkip})
+	var first, last Knot // knots at the edges of the line, for protrusion
+	for _, knot := range append(append([]Knot{}, post...), kh.knots[from:end]...) {
+		if IsEdgeKnot(knot) {
+			if first == nil {
+				first = knot
+			}
+			last = knot
+		}
+	}
+	if last != nil && last.Type() == KTDiscretionary { // not broken
+		last = nil
+	}
+	if skips.Protrusion != nil && first != nil {
+		if p := skips.Protrusion.LeftProtrusion(first); p != 0 {
+			line.Knots = append(line.Knots, PositionedKnot{Knot: Kern(-p)})
+		}
+	}
+	for _, knot := range post {
+		line.Knots = append(line.Knots, PositionedKnot{Knot: knot})
+	}
+	for _, knot := range kh.knots[from:end] {
+		switch knot.Type() {
+		case KTPenalty:
+			continue
+		case KTDiscretionary: // not broken
+			for _, k := range knot.(Discretionary).NoBreak {
+				line.Knots = append(line.Knots, PositionedKnot{Knot: k})
+			}
+			continue
+		}
+		line.Knots = append(line.Knots, PositionedKnot{Knot: knot})
+	}
+	if to < len(kh.knots) { // replace a discretionary at the breakpoint by its pre-break knots
+		if d, ok := kh.knots[to].(Discretionary); ok {
+			for _, k := range d.PreBreak() {
+				line.Knots = append(line.Knots, PositionedKnot{Knot: k})
+			}
+			last = d
+		}
+	}
+	if skips.Protrusion != nil && last != nil {
+		if p := skips.Protrusion.RightProtrusion(last); p != 0 {
+			line.Knots = append(line.Knots, PositionedKnot{Knot: Kern(-p)})
+		}
+	}
+	if final {
+		line.Knots = append(line.Knots, PositionedKnot{Knot: skips.ParFillSkip})
+	}
+	line.Knots = append(line.Knots, PositionedKnot{Knot: skips.RightSkip})
+	line.Height, line.Depth = kh.MaxHeightAndDepth(from, end)
+	return line
+}
+
+// setGlue calculates the glue set ratio of a line and positions its knots.
+func (l *Line) setGlue() {
+	var w dimen.Dimen
+	var shrink int64
+	var stretch [4]int64 // stretchability per order; sums of infinite glue overflow dimensions
+	for _, pk := range l.Knots {
+		w += pk.Knot.W()
+		s := pk.Knot.MaxW() - pk.Knot.W()
+		stretch[stretchOrder(s)] += int64(s)
+		shrink += int64(pk.Knot.W() - pk.Knot.MinW())
+		if box, ok := pk.Knot.(*TextBox); ok {
+			stretch[0] += int64(box.W().MulRatio(int64(l.stretch), 1000))
+			shrink += int64(box.W().MulRatio(int64(l.shrink), 1000))
+		}
+	}
+	l.Natural = w
+	l.Order = 3
+	for l.Order > 0 && stretch[l.Order] == 0 {
+		l.Order--
+	}
+	if w < l.Width && stretch[l.Order] > 0 {
+		l.GlueSet = float64(l.Width-w) / float64(stretch[l.Order])
+	} else if w > l.Width && shrink > 0 {
+		l.GlueSet = -float64(w-l.Width) / float64(shrink)
+		if l.GlueSet < -1.0 {
+			CT().Infof("Overfull box: line is %.2fbp too wide", (w - dimen.Dimen(shrink) - l.Width).Points())
+			l.GlueSet = -1.0
+		}
+	}
+	var x dimen.Dimen
+	for i := range l.Knots {
+		pk := &l.Knots[i]
+		pk.X = x
+		if g, ok := pk.Knot.(Glue); ok {
+			pk.W = l.GlueWidth(g)
+		} else if box, ok := pk.Knot.(*TextBox); ok {
+			pk.W = l.BoxWidth(box)
+		} else {
+			pk.W = pk.Knot.W()
+		}
+		x += pk.W
+	}
+}
+
+// stretchOrder returns the order of infinity for a glue's stretchability:
+// 0 for finite stretch, 1 for fil, 2 for fill and 3 for filll.
+func stretchOrder(s dimen.Dimen) int {
+	switch {
+	case s >= dimen.Filll:
+		return 3
+	case s >= dimen.Fill:
+		return 2
+	case s >= dimen.Fil:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package khipu
+
+import (
+	"strings"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// Explicit hyphens and soft hyphens:
+//
+// A hyphen already present in the text is a break opportunity. The hyphen
+// stays with the text preceding it and is followed by an explicit (empty)
+// discretionary, which the Knuth-Plass line breaker will charge with
+// ExHyphenPenalty. The first-fit line breaker does not break at discretionaries,
+// neither at explicit nor at regular ones.
+// The components of a compound word are hyphenated separately.
+//
+// A soft hyphen (U+00AD) is an invisible, user-specified hyphenation
+// opportunity. It is removed from the text and replaced by a regular
+// discretionary. Words containing soft hyphens are not hyphenated
+// by patterns.
+
+const softHyphen = '\u00AD'
+
+// isHyphen checks if r is an explicit hyphen.
+func isHyphen(r rune) bool {
+	return r == '-' || r == '\u2010' // hyphen-minus or hyphen
+}
+
+// hasSoftHyphen checks if a word contains user-specified hyphenation positions.
+func hasSoftHyphen(word string) bool {
+	return strings.ContainsRune(word, softHyphen)
+}
+
+// ExplicitHyphens turns explicit hyphens and soft hyphens within text boxes
+// of a khipu into discretionaries.
+func ExplicitHyphens(khipu *Khipu, pipeline *TypesettingPipeline) {
+	if khipu == nil {
+		return
+	}
+	k := make([]Knot, 0, khipu.Length())
+	for i := 0; i < len(khipu.knots); i++ {
+		box, ok := khipu.knots[i].(*TextBox)
+		if !ok || (!hasSoftHyphen(box.text) && strings.IndexFunc(box.text, isHyphen) < 0) {
+			k = append(k, khipu.knots[i])
+			continue
+		}
+		afterText := len(k) > 0 && k[len(k)-1].Type() == KTTextBox
+		parts, hyphens := pipeline.splitAtHyphens(box.text, afterText)
+		for j, part := range parts {
+			if part == box.text {
+				k = append(k, box)
+			} else if part != "" {
+				k = append(k, pipeline.newTextBox(part))
+			}
+			if hyphens[j] != nil && j < len(parts)-1 {
+				k = append(k, hyphens[j])
+			}
+		}
+		trailing := hyphens[len(hyphens)-1]
+		if trailing == nil || i+1 == len(khipu.knots) {
+			continue
+		}
+		// a hyphen at the end of the box: break only if a break opportunity
+		// or more text follows
+		if p, ok := khipu.knots[i+1].(Penalty); ok && p < Penalty(dimen.Infty) {
+			k = append(k, trailing) // replaces the penalty
+			i++
+		} else if khipu.knots[i+1].Type() == KTTextBox {
+			k = append(k, trailing)
+		}
+	}
+	khipu.knots = k
+}
+
+// splitAtHyphens splits a text after explicit hyphens and at soft hyphens,
+// removing the latter. Every part is paired with the discretionary following
+// it, or nil. A hyphen is a break opportunity only if text precedes it, either
+// within the text or in a preceding text box.
+func (pipeline *TypesettingPipeline) splitAtHyphens(text string, afterText bool) ([]string, []Knot) {
+	var parts []string
+	var hyphens []Knot
+	var part strings.Builder
+	hasText := afterText
+	for _, r := range text {
+		switch {
+		case r == softHyphen:
+			if hasText {
+				parts = append(parts, part.String())
+				hyphens = append(hyphens, pipeline.newDiscretionary())
+				part.Reset()
+			}
+		case isHyphen(r) && hasText:
+			part.WriteRune(r)
+			parts = append(parts, part.String())
+			hyphens = append(hyphens, Discretionary{})
+			part.Reset()
+			hasText = false
+		default:
+			part.WriteRune(r)
+			hasText = !isHyphen(r)
+		}
+	}
+	if part.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, part.String())
+		hyphens = append(hyphens, nil)
+	}
+	return parts, hyphens
+}
@@ -0,0 +1,256 @@
+package khipu
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// Chinese and Japanese text does not use spaces between words. Lines may be
+// broken between any two characters, except where kinsoku rules prohibit it:
+// closing brackets, punctuation and small kana must not start a line, opening
+// brackets must not end a line. Justification is done by stretching
+// inter-character glue.
+//
+// Scripts like Thai do not use spaces between words either, but lines may only
+// be broken between words. Words are found by dictionary-based word breakers,
+// which may be set for a script with SetWordBreaker.
+
+// interCharStretchRatio determines the stretchability of inter-character glue,
+// relative to the interword space of the font.
+const interCharStretchRatio = 4
+
+// WordBreaker splits text without spaces into words. It is used for scripts
+// like Thai, Lao or Khmer, where word boundaries are determined by a
+// dictionary.
+type WordBreaker interface {
+	BreakWords(text string) []string
+}
+
+type scriptWordBreaker struct {
+	script *unicode.RangeTable
+	wb     WordBreaker
+}
+
+// SetWordBreaker sets a word breaker for runs of text in a script, e.g.
+//
+//	pipeline.SetWordBreaker(unicode.Thai, thaiDictionaryBreaker)
+//
+// Setting a word breaker of nil removes the word breaker for a script.
+func (pipeline *TypesettingPipeline) SetWordBreaker(script *unicode.RangeTable, wb WordBreaker) {
+	breakers := make([]scriptWordBreaker, 0, len(pipeline.wordbreakers)+1)
+	for _, swb := range pipeline.wordbreakers {
+		if swb.script != script {
+			breakers = append(breakers, swb)
+		}
+	}
+	if wb != nil {
+		breakers = append(breakers, scriptWordBreaker{script: script, wb: wb})
+	}
+	pipeline.wordbreakers = breakers
+}
+
+// wordBreakerFor returns the word breaker for the script of a rune, if any.
+func (pipeline *TypesettingPipeline) wordBreakerFor(r rune) (*unicode.RangeTable, WordBreaker) {
+	for _, swb := range pipeline.wordbreakers {
+		if unicode.Is(swb.script, r) {
+			return swb.script, swb.wb
+		}
+	}
+	return nil, nil
+}
+
+// InterCharacterBreaks inserts breakpoints into text without spaces.
+// Text boxes containing Chinese or Japanese characters are split into single
+// characters, text boxes containing runs of a script with a word breaker (see
+// SetWordBreaker) are split into words. Every split is separated by
+// inter-character glue, followed by a penalty of 0 or, where kinsoku rules
+// prohibit a line break, of infinity.
+//
+// Penalties between text boxes, as inserted by the line wrapper for
+// break opportunities without whitespace, are replaced by inter-character glue
+// and a penalty in the same way, if adjacent to Chinese or Japanese characters.
+func InterCharacterBreaks(khipu *Khipu, pipeline *TypesettingPipeline) {
+	if khipu == nil {
+		return
+	}
+	if pipeline == nil {
+		pipeline = &TypesettingPipeline{}
+	}
+	k := make([]Knot, 0, len(khipu.knots))
+	for i, knot := range khipu.knots {
+		switch kn := knot.(type) {
+		case *TextBox:
+			pieces := pipeline.splitText(kn.text)
+			if len(pieces) <= 1 {
+				k = append(k, knot)
+				continue
+			}
+			for j, piece := range pieces {
+				if j > 0 {
+					k = pipeline.appendInterCharBreak(k, pieces[j-1], piece)
+				}
+				k = append(k, pipeline.newTextBox(piece))
+			}
+		case Penalty:
+			if len(k) > 0 && i+1 < len(khipu.knots) && kn.Demerits() < int32(dimen.Infty) {
+				prev, ok1 := k[len(k)-1].(*TextBox)
+				next, ok2 := khipu.knots[i+1].(*TextBox)
+				if ok1 && ok2 && (isCJK(lastRune(prev.text)) || isCJK(firstRune(next.text))) {
+					k = pipeline.appendInterCharBreak(k, prev.text, next.text)
+					continue
+				}
+			}
+			k = append(k, knot)
+		default:
+			k = append(k, knot)
+		}
+	}
+	khipu.knots = k
+}
+
+// appendInterCharBreak appends inter-character glue and a penalty between two
+// pieces of text.
+func (pipeline *TypesettingPipeline) appendInterCharBreak(k []Knot, before, after string) []Knot {
+	g := NewGlue(0, 0, pipeline.fontSpace()[0]/interCharStretchRatio)
+	p := Penalty(0)
+	if noBreakAfter(lastRune(before)) || noBreakBefore(firstRune(after)) {
+		p = Penalty(dimen.Infty)
+	}
+	return append(k, g, p)
+}
+
+// splitText splits a text into single Chinese or Japanese characters and into
+// words of scripts with a word breaker. Other runs of text are left intact.
+func (pipeline *TypesettingPipeline) splitText(text string) []string {
+	var pieces []string
+	var script *unicode.RangeTable // script of the current run, if it has a word breaker
+	var wb WordBreaker
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			if wb != nil {
+				pieces = append(pieces, wb.BreakWords(text[start:end])...)
+			} else {
+				pieces = append(pieces, text[start:end])
+			}
+		}
+		start = end
+	}
+	single := false // current run is a single CJK character
+	for i, r := range text {
+		if unicode.In(r, unicode.Mn, unicode.Me, unicode.Variation_Selector) {
+			continue // combining marks stay with their base character
+		}
+		if isCJK(r) {
+			flush(i)
+			script, wb, single = nil, nil, true
+			continue
+		}
+		if s, w := pipeline.wordBreakerFor(r); single || s != script {
+			flush(i)
+			script, wb, single = s, w, false
+		}
+	}
+	flush(len(text))
+	return pieces
+}
+
+// isCJK is true for Chinese and Japanese characters, including CJK punctuation
+// and full-width forms.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// Kinsoku rules, following JIS X 4051.
+const (
+	kinsokuNoLineStart = ")]}）］｝〕〉》」』】〙〗〟’”｠»、。，．,.:;!?！？：；・ーヽヾゝゞ々〻‐゠–〜～" +
+		"ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶ"
+	kinsokuNoLineEnd = "([{（［｛〔〈《「『【〘〖〝‘“｟«"
+)
+
+func noBreakBefore(r rune) bool {
+	return containsRune(kinsokuNoLineStart, r)
+}
+
+func noBreakAfter(r rune) bool {
+	return containsRune(kinsokuNoLineEnd, r)
+}
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// --- Dictionary word breaker -----------------------------------------------
+
+type dictionaryWordBreaker struct {
+	words  map[string]bool
+	maxlen int // maximum length of a word in runes
+}
+
+// NewDictionaryWordBreaker creates a word breaker from a list of words.
+// It splits text by longest match: at every position, the longest word from
+// the dictionary is taken. Runs of text not matching any word are kept together.
+func NewDictionaryWordBreaker(words ...string) WordBreaker {
+	dict := &dictionaryWordBreaker{words: make(map[string]bool, len(words))}
+	for _, w := range words {
+		dict.words[w] = true
+		if l := utf8.RuneCountInString(w); l > dict.maxlen {
+			dict.maxlen = l
+		}
+	}
+	return dict
+}
+
+// BreakWords is part of interface WordBreaker.
+func (dict *dictionaryWordBreaker) BreakWords(text string) []string {
+	runes := []rune(text)
+	var words []string
+	unknown := -1 // start of a run of text not in the dictionary
+	for i := 0; i < len(runes); {
+		l := dict.longestMatch(runes[i:])
+		if l == 0 {
+			if unknown < 0 {
+				unknown = i
+			}
+			i++
+			continue
+		}
+		if unknown >= 0 {
+			words = append(words, string(runes[unknown:i]))
+			unknown = -1
+		}
+		words = append(words, string(runes[i:i+l]))
+		i += l
+	}
+	if unknown >= 0 {
+		words = append(words, string(runes[unknown:]))
+	}
+	return words
+}
+
+func (dict *dictionaryWordBreaker) longestMatch(runes []rune) int {
+	for l := iMin(dict.maxlen, len(runes)); l > 0; l-- {
+		if dict.words[string(runes[:l])] {
+			return l
+		}
+	}
+	return 0
+}
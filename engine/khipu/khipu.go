@@ -5,6 +5,9 @@ import (
 	"fmt"
 
 	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/core/font"
+	params "github.com/npillmayer/gotype/core/parameters"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
 )
 
 /*
@@ -65,6 +68,13 @@ const (
 	KTTextBox
 	KTPenalty
 	KTDiscretionary
+	KTLine        // a packed line, as part of a vertical list
+	KTInsert      // material to insert on a page, e.g., footnotes
+	KTHBox        // a box containing a horizontal list
+	KTVBox        // a box containing a vertical list
+	KTRule        // a filled rectangle
+	KTImage       // an inline image
+	KTWhatsit     // an opaque payload for the renderer
 	KTUserDefined // clients should use custom knot types above this
 )
 
@@ -105,6 +115,12 @@ func KnotString(k Knot) string {
 		return k.(TextBox).String()
 	case KTDiscretionary:
 		return "\u2af6"
+	case KTLine:
+		return k.(*Line).String()
+	case KTInsert:
+		return k.(Insert).String()
+	case KTHBox, KTVBox, KTRule, KTImage, KTWhatsit:
+		return k.(fmt.Stringer).String()
 	default:
 		return "yes, it is a knot"
 	}
@@ -202,10 +218,40 @@ func NewFill(f int) Glue {
 
 // --- Discretionary ---------------------------------------------------------
 
-// A Discretionary is a hyphenation opportunity
+// A Discretionary is a hyphenation opportunity, modelled after TeX's
+// \discretionary{pre}{post}{nobreak}: if a line is broken at the discretionary,
+// knots Pre end the line and knots Post start the next line. Otherwise knots
+// NoBreak are set. This allows for spelling changes at hyphenation points,
+// e.g., in old German orthography "backen" is hyphenated as "bak-ken".
+//
+// For the common case of a plain hyphenation opportunity, Pre, Post and NoBreak
+// are empty and the hyphen is given by HyphenChar and Width.
+// A discretionary without a hyphen character and without pre-break knots
+// follows an explicit hyphen, which already is part of the text (an empty
+// discretionary in TeX terms).
 type Discretionary struct {
 	HyphenChar rune
 	Width      dimen.Dimen
+	Pre        []Knot // knots at the end of a line broken at the discretionary
+	Post       []Knot // knots at the start of a line following the discretionary
+	NoBreak    []Knot // knots if the line is not broken at the discretionary
+}
+
+// IsExplicit returns true for a discretionary following an explicit hyphen.
+// Breaking at an explicit discretionary does not insert a hyphen.
+func (d Discretionary) IsExplicit() bool {
+	return d.HyphenChar == 0 && len(d.Pre) == 0
+}
+
+// PreBreak returns the knots at the end of a line broken at d. For a plain
+// discretionary, this is a text box containing the hyphen.
+func (d Discretionary) PreBreak() []Knot {
+	if len(d.Pre) > 0 || d.HyphenChar == 0 {
+		return d.Pre
+	}
+	hyphen := NewTextBox(string(d.HyphenChar))
+	hyphen.Width = d.Width
+	return []Knot{hyphen}
 }
 
 // Type is part of interface Knot.
@@ -215,17 +261,31 @@ func (d Discretionary) Type() KnotType {
 
 // W is part of interface Knot. Returns the width of the un-hyphenated text.
 func (d Discretionary) W() dimen.Dimen {
-	return 0
+	var w dimen.Dimen
+	for _, k := range d.NoBreak {
+		w += k.W()
+	}
+	return w
 }
 
-// MinW is part of interface Knot. Returns the width of the pre-hyphen text.
+// MinW is part of interface Knot. Returns the minimum width of the
+// un-hyphenated text.
 func (d Discretionary) MinW() dimen.Dimen {
-	return 0
+	var w dimen.Dimen
+	for _, k := range d.NoBreak {
+		w += k.MinW()
+	}
+	return w
 }
 
-// MaxW is part of interface Knot. Returns the width of the post-hyphen text.
+// MaxW is part of interface Knot. Returns the maximum width of the
+// un-hyphenated text.
 func (d Discretionary) MaxW() dimen.Dimen {
-	return d.Width
+	var w dimen.Dimen
+	for _, k := range d.NoBreak {
+		w += k.MaxW()
+	}
+	return w
 }
 
 // IsDiscardable is part of interface Knot. Discretionaries are not discardable.
@@ -242,6 +302,8 @@ type TextBox struct {
 	Depth  dimen.Dimen // depth
 	text   string      // text, if available
 	//knotlist Khipu // content, if available
+	glyphs   textshaping.GlyphSequence // shaped glyphs, if available
+	typecase *font.TypeCase            // font of the glyphs
 }
 
 // NewTextBox creates a text box.
@@ -334,8 +396,9 @@ func (p Penalty) Demerits() int32 {
 // Khipu is a string of knots.
 // We handle text/paragraphs as khipus.
 type Khipu struct {
-	typ   int    // hlist, vlist or mlist
-	knots []Knot // array of knots of different type
+	typ   int             // hlist, vlist or mlist
+	knots []Knot          // array of knots of different type
+	regs  params.Snapshot // typesetting registers in effect when encoding
 }
 
 // List types
@@ -352,6 +415,17 @@ func NewKhipu() *Khipu {
 	return kh
 }
 
+// Registers returns the state of the typesetting registers at the time the
+// khipu has been encoded (see KnotEncode).
+func (kh *Khipu) Registers() params.Snapshot {
+	return kh.regs
+}
+
+// SetRegisters attaches a snapshot of typesetting registers to a khipu.
+func (kh *Khipu) SetRegisters(regs params.Snapshot) {
+	kh.regs = regs
+}
+
 // Length gives the number of knots in the list.
 func (kh *Khipu) Length() int {
 	return len(kh.knots)
@@ -383,11 +457,11 @@ func (kh *Khipu) ReplaceKnot(inx int, knot Knot) Knot {
 }
 
 // Measure returns the widths of a subset of this knot list. The subset runs from
-// index [from ... to-1]. The method returns natural, maximum and minimum
-// width.
+// index [from ... to-1]. If to is negative, the subset extends to the end of the
+// khipu. The method returns natural, maximum and minimum width.
 func (kh *Khipu) Measure(from, to int) (dimen.Dimen, dimen.Dimen, dimen.Dimen) {
 	var w, max, min dimen.Dimen
-	to = iMax(to, len(kh.knots))
+	to = kh.upperBound(to)
 	for i := from; i < to; i++ {
 		knot := kh.knots[i]
 		w += knot.W()
@@ -421,8 +495,9 @@ func (kh *Khipu) Reach(start int, distance dimen.Dimen) (int, int) {
 }
 
 // MaxWidth finds the maximum width of the knots in the range [from ... to-1].
+// If to is negative, the range extends to the end of the khipu.
 func (kh *Khipu) MaxWidth(from, to int) dimen.Dimen {
-	to = iMax(to, len(kh.knots))
+	to = kh.upperBound(to)
 	var w dimen.Dimen
 	for i := from; i < to; i++ {
 		knot := kh.knots[i]
@@ -435,17 +510,17 @@ func (kh *Khipu) MaxWidth(from, to int) dimen.Dimen {
 
 // MaxHeightAndDepth finds the maximum height and depth of the knots in the range
 // [from ... to-1].
-// Only knots of type TextBox are considered.
+// Only knots with a height and depth (text, boxes, rules and images) are considered.
 func (kh *Khipu) MaxHeightAndDepth(from, to int) (dimen.Dimen, dimen.Dimen) {
 	to = iMax(from, iMin(to, len(kh.knots)))
 	var h, d dimen.Dimen
 	for i := from; i < to; i++ {
-		if knot, ok := kh.knots[i].(*TextBox); ok {
-			if knot.Height > h {
-				h = knot.Height
+		if bh, bd, ok := heightAndDepth(kh.knots[i]); ok {
+			if bh > h {
+				h = bh
 			}
-			if knot.Depth > d {
-				d = knot.Depth
+			if bd > d {
+				d = bd
 			}
 		}
 	}
@@ -462,6 +537,16 @@ func (kh *Khipu) Text(from, to int) string {
 		if knot.Type() == KTTextBox {
 			b.WriteString(knot.(*TextBox).text)
 			spacecnt = 0
+		} else if knot.Type() == KTDiscretionary {
+			for _, k := range knot.(Discretionary).NoBreak {
+				if box, ok := k.(*TextBox); ok {
+					b.WriteString(box.text)
+				}
+			}
+		} else if knot.Type() == KTHBox {
+			content := knot.(*HBox).Content
+			b.WriteString(content.Text(0, content.Length()))
+			spacecnt = 0
 		} else if knot.Type() == KTGlue {
 			if spacecnt == 0 {
 				b.WriteString(" ")
@@ -502,6 +587,15 @@ func (kh *Khipu) String() string {
 
 // ----------------------------------------------------------------------
 
+// upperBound clips an exclusive upper index to the length of the khipu.
+// A negative index denotes the end of the khipu.
+func (kh *Khipu) upperBound(to int) int {
+	if to < 0 || to > len(kh.knots) {
+		return len(kh.knots)
+	}
+	return to
+}
+
 func iMin(x, y int) int {
 	if x < y {
 		return x
@@ -3,6 +3,7 @@ package khipu
 import (
 	"strings"
 	"testing"
+	"unicode"
 
 	"github.com/npillmayer/gotype/core/config/configtestadapter"
 	"github.com/npillmayer/gotype/core/config/gconf"
@@ -10,7 +11,11 @@ import (
 	"github.com/npillmayer/gotype/core/config/tracing"
 	"github.com/npillmayer/gotype/core/config/tracing/gotestingadapter"
 	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/core/font"
 	"github.com/npillmayer/gotype/core/parameters"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
+	"github.com/npillmayer/gotype/gtlocate"
+	"golang.org/x/image/font/sfnt"
 )
 
 func init() {
@@ -45,6 +50,7 @@ func TestBreaking1(t *testing.T) {
 	gtrace.CoreTracer.SetTraceLevel(tracing.LevelInfo)
 	regs := parameters.NewTypesettingRegisters()
 	regs.Push(parameters.P_MINHYPHENLENGTH, 3)
+	regs.Push(parameters.P_RIGHTHYPHENMIN, 2) // allow Hel-lo
 	kh := KnotEncode(strings.NewReader("Hello World "), nil, regs)
 	if kh.Length() != 10 {
 		t.Logf("khipu = %s", kh)
@@ -80,6 +86,18 @@ func TestText(t *testing.T) {
 	}
 }
 
+func TestKhipuRegisters(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	regs := parameters.NewTypesettingRegisters()
+	regs.Push(parameters.P_LANGUAGE, "de_DE")
+	kh := KnotEncode(strings.NewReader("Hallo"), nil, regs)
+	regs.Push(parameters.P_LANGUAGE, "en_US")
+	if lang := kh.Registers().S(parameters.P_LANGUAGE); lang != "de_DE" {
+		t.Errorf("expected khipu to remember language de_DE, is %s", lang)
+	}
+}
+
 func TestExHyphen(t *testing.T) {
 	teardown := gotestingadapter.RedirectTracing(t)
 	defer teardown()
@@ -94,3 +112,438 @@ func TestExHyphen(t *testing.T) {
 		t.Errorf("output text != input text")
 	}
 }
+
+func TestExplicitHyphens(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelDebug)
+	discretionaries := func(kh *Khipu) (explicit, soft int) {
+		for _, knot := range kh.knots {
+			if d, ok := knot.(Discretionary); ok {
+				if d.IsExplicit() {
+					explicit++
+				} else {
+					soft++
+				}
+			}
+		}
+		return
+	}
+	regs := parameters.NewTypesettingRegisters()
+	regs.Push(parameters.P_MINHYPHENLENGTH, dimen.Infty)
+	kh := KnotEncode(strings.NewReader("lime-tree and -5"), nil, regs)
+	if explicit, _ := discretionaries(kh); explicit != 1 {
+		t.Errorf("expected 1 explicit discretionary for 'lime-tree and -5', have %d: %s", explicit, kh)
+	}
+	text := "hy\u00ADphen"
+	kh = KnotEncode(strings.NewReader(text), nil, regs)
+	if _, soft := discretionaries(kh); soft != 1 {
+		t.Errorf("expected soft hyphen to be a discretionary, have %s", kh)
+	}
+	if out := kh.Text(0, kh.Length()); out != "hyphen" {
+		t.Errorf("expected soft hyphen to be removed from text, have %q", out)
+	}
+	// words containing soft hyphens are not hyphenated by patterns
+	regs.Push(parameters.P_MINHYPHENLENGTH, 3)
+	kh = KnotEncode(strings.NewReader("hyphen\u00ADation"), nil, regs)
+	if _, soft := discretionaries(kh); soft != 1 {
+		t.Errorf("expected only user-specified hyphenation, have %s", kh)
+	}
+	kh = KnotEncode(strings.NewReader("time-consuming"), nil, regs)
+	if explicit, soft := discretionaries(kh); explicit != 1 || soft == 0 {
+		t.Errorf("expected compound components to be hyphenated, have %s", kh)
+	}
+}
+
+type fixedLength dimen.Dimen
+
+func (l fixedLength) LineLength(int) dimen.Dimen {
+	return dimen.Dimen(l)
+}
+
+func TestHPack(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelDebug)
+	box := func(s string) *TextBox {
+		b := NewTextBox(s)
+		b.Width = 20 * dimen.BP
+		return b
+	}
+	kh := NewKhipu()
+	kh.AppendKnot(box("aa")).AppendKnot(NewGlue(5*dimen.BP, dimen.BP, 2*dimen.BP))
+	kh.AppendKnot(box("bb")).AppendKnot(NewKnot(KTDiscretionary)).AppendKnot(box("cc"))
+	kh.AppendKnot(NewGlue(5*dimen.BP, dimen.BP, 2*dimen.BP)).AppendKnot(box("dd"))
+	kh.AppendKnot(Penalty(-10000))
+	breaks := []Mark{mark{pos: -1}, mark{pos: 3, knot: kh.knots[3]}, mark{pos: 7, knot: kh.knots[7]}}
+	lines := HPack(kh, breaks, fixedLength(52*dimen.BP), Skips{ParFillSkip: NewFill(2)})
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	l := lines[0] // skip aa glue bb hyphen skip
+	if len(l.Knots) != 6 || l.Knots[4].Knot.(*TextBox).Text() != "-" {
+		t.Errorf("expected first line to end with a hyphen, is %s", l)
+	}
+	if l.GlueSet != 1.0 || l.Knots[3].X != 27*dimen.BP || l.Knots[4].X != 47*dimen.BP {
+		t.Errorf("expected glue of first line to stretch by 2bp, is %s", l)
+	}
+	l = lines[1]
+	if l.Natural != 45*dimen.BP || l.Knots[len(l.Knots)-2].X != 45*dimen.BP {
+		t.Errorf("expected last line to be filled by parfillskip, is %s", l)
+	}
+}
+
+func TestDiscretionaryStretch(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	box := func(s string) *TextBox {
+		b := NewTextBox(s)
+		b.Width = 20 * dimen.BP
+		return b
+	}
+	kh := NewKhipu()
+	kh.AppendKnot(box("aa")).AppendKnot(NewGlue(5*dimen.BP, dimen.BP, 2*dimen.BP))
+	kh.AppendKnot(box("bb")).AppendKnot(NewKnot(KTDiscretionary)).AppendKnot(box("cc"))
+	kh.AppendKnot(Penalty(-10000))
+	w, max, min := kh.Measure(0, -1)
+	if w != 65*dimen.BP || max != 67*dimen.BP || min != 64*dimen.BP {
+		t.Errorf("expected plain discretionary to add no width, have w=%s max=%s min=%s", w, max, min)
+	}
+	breaks := []Mark{mark{pos: -1}, mark{pos: 5, knot: kh.knots[5]}}
+	lines := HPack(kh, breaks, fixedLength(67*dimen.BP), Skips{})
+	if len(lines) != 1 || lines[0].GlueSet != 1.0 {
+		t.Errorf("expected glue to stretch to its maximum, is %v", lines)
+	}
+}
+
+func TestHPackFills(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	box := NewTextBox("aa")
+	box.Width = 40 * dimen.BP
+	kh := NewKhipu().AppendKnot(box).AppendKnot(Penalty(-10000))
+	breaks := []Mark{mark{pos: -1}, mark{pos: 1, knot: kh.knots[1]}}
+	skips := Skips{RightSkip: NewFill(2), ParFillSkip: NewFill(2)}
+	lines := HPack(kh, breaks, fixedLength(100*dimen.BP), skips)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d", len(lines))
+	}
+	l := lines[0] // skip aa parfillskip skip
+	if l.Order != 2 || l.GlueSet <= 0 {
+		t.Fatalf("expected line to be filled by 2 fill glues, is %s", l)
+	}
+	if l.Knots[2].W != 30*dimen.BP || l.Knots[3].X != 70*dimen.BP {
+		t.Errorf("expected fill glues to share 60bp, are %s and %s", l.Knots[2].W, l.Knots[3].W)
+	}
+}
+
+func TestSpellingChanges(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelDebug)
+	for _, c := range []struct {
+		change      SpellingChange
+		left, right string
+		broken      string
+	}{
+		{GermanCK, "Zuc", "ker", "Zuk-ker"},
+		{DutchDiaeresis, "re", "ële", "re-ele"},
+		{HungarianLongConsonants, "as", "szony", "asz-szony"},
+	} {
+		h, ok := c.change(c.left, c.right)
+		if !ok || h.Left+h.Pre+h.Post+h.Right != c.broken || h.Left+h.NoBreak+h.Right != c.left+c.right {
+			t.Errorf("expected %s+%s to be hyphenated as %s, is %+v", c.left, c.right, c.broken, h)
+		}
+	}
+	if _, ok := GermanCK("Ta", "sche"); ok {
+		t.Errorf("expected regular hyphenation for Ta-sche")
+	}
+	if spellingChangeFor("de-DE-1901") == nil || spellingChangeFor("de_DE") != nil || spellingChangeFor("hu") == nil {
+		t.Errorf("spelling changes not correctly associated with languages")
+	}
+	var pipeline *TypesettingPipeline
+	kh := NewKhipu()
+	for _, knot := range pipeline.hyphenatedKnots([]string{"Zuc", "ker"}, GermanCK) {
+		kh.AppendKnot(knot)
+	}
+	if out := kh.Text(0, kh.Length()); out != "Zucker" {
+		t.Errorf("expected unbroken text to read 'Zucker', is %q", out)
+	}
+	kh.AppendKnot(Penalty(-10000))
+	breaks := []Mark{mark{pos: -1}, mark{pos: 1, knot: kh.knots[1]}, mark{pos: 3, knot: kh.knots[3]}}
+	lines := HPack(kh, breaks, fixedLength(50*dimen.BP), Skips{ParFillSkip: NewFill(2)})
+	text := func(l *Line) string {
+		var b strings.Builder
+		for _, pk := range l.Knots {
+			if box, ok := pk.Knot.(*TextBox); ok {
+				b.WriteString(box.Text())
+			}
+		}
+		return b.String()
+	}
+	if len(lines) != 2 || text(lines[0]) != "Zuk-" || text(lines[1]) != "ker" {
+		t.Errorf("expected lines 'Zuk-' and 'ker', have %v", lines)
+	}
+}
+
+func TestVListBaselineskip(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	regs := parameters.NewTypesettingRegisters()
+	regs.Push(parameters.P_BASELINESKIP, 12*dimen.BP)
+	regs.Push(parameters.P_LINESKIP, dimen.BP)
+	regs.Push(parameters.P_LINESKIPLIMIT, dimen.Dimen(0))
+	lines := []*Line{
+		{Height: 8 * dimen.BP, Depth: 2 * dimen.BP},
+		{Height: 8 * dimen.BP, Depth: 3 * dimen.BP},
+		{Height: 10 * dimen.BP, Depth: 2 * dimen.BP},
+	}
+	vlist := NewVList().AppendLines(lines, regs)
+	t.Logf("vlist = %s", vlist)
+	// line ⦻club glue line ⦻widow glue line
+	if vlist.Length() != 7 {
+		t.Fatalf("expected vertical list of length 7, is %d", vlist.Length())
+	}
+	if g := vlist.knots[2].(Glue); g.W() != 2*dimen.BP {
+		t.Errorf("expected baselineskip glue of 2bp, is %v", g)
+	}
+	if g := vlist.knots[5].(Glue); g.W() != dimen.BP {
+		t.Errorf("expected lineskip glue of 1bp, is %v", g)
+	}
+	if p := vlist.knots[1].(Penalty); p != 150 {
+		t.Errorf("expected club penalty of 150, is %d", p)
+	}
+}
+
+func TestBoxes(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	word := NewTextBox("box")
+	word.Width, word.Height, word.Depth = 20*dimen.BP, 8*dimen.BP, 2*dimen.BP
+	content := NewKhipu().AppendKnot(word).AppendKnot(NewGlue(5*dimen.BP, 0, 5*dimen.BP))
+	box := NewHBoxTo(content, 30*dimen.BP)
+	kh := NewKhipu().AppendKnot(NewWhatsit("link", "#anchor")).AppendKnot(box)
+	kh.AppendKnot(NewRule(10*dimen.BP, 12*dimen.BP, 0))
+	kh.AppendKnot(NewImage("logo.png", 15*dimen.BP, 10*dimen.BP))
+	t.Logf("khipu = %s", kh)
+	if w, _, _ := kh.Measure(0, -1); w != 55*dimen.BP {
+		t.Errorf("expected khipu to be 55bp wide, is %.2fbp", w.Points())
+	}
+	if h, d := kh.MaxHeightAndDepth(0, kh.Length()); h != 12*dimen.BP || d != 2*dimen.BP {
+		t.Errorf("expected height 12bp and depth 2bp, are %.2fbp and %.2fbp", h.Points(), d.Points())
+	}
+	if line := box.Pack(); line.GlueSet != 1.0 {
+		t.Errorf("expected hbox content to stretch by 5bp, is %s", line)
+	}
+	if kh.Text(0, kh.Length()) != "box " {
+		t.Errorf("expected text of hbox to be 'box ', is '%s'", kh.Text(0, kh.Length()))
+	}
+}
+
+// fakeShaper maps runes to glyphs of a font, each glyph advancing by 5pt.
+type fakeShaper struct{}
+
+type fakeGlyph rune
+type fakeGlyphs []fakeGlyph
+
+func (g fakeGlyph) Glyph() rune                                     { return rune(g) }
+func (g fakeGlyph) Cluster() int                                    { return 0 }
+func (g fakeGlyph) XAdvance() float64                               { return 5.0 }
+func (g fakeGlyph) YAdvance() float64                               { return 0 }
+func (g fakeGlyph) XPosition() float64                              { return 0 }
+func (g fakeGlyph) YPosition() float64                              { return 0 }
+func (seq fakeGlyphs) GlyphCount() int                              { return len(seq) }
+func (seq fakeGlyphs) GetGlyphInfoAt(pos int) textshaping.GlyphInfo { return seq[pos] }
+
+func (fakeShaper) Shape(text string, tc *font.TypeCase) textshaping.GlyphSequence {
+	var seq fakeGlyphs
+	for _, r := range text {
+		gid, _ := tc.ScalableFontParent().SFNT.GlyphIndex(&sfnt.Buffer{}, r)
+		seq = append(seq, fakeGlyph(gid))
+	}
+	return seq
+}
+func (fakeShaper) SetScript(scr textshaping.ScriptID)         {}
+func (fakeShaper) SetDirection(dir textshaping.TextDirection) {}
+func (fakeShaper) SetLanguage()                               {}
+
+func TestShapedTextBox(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	f, err := font.LoadOpenTypeFont(gtlocate.FileResource("GentiumPlus-R.ttf", "font"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, _ := f.PrepareCase(10.0)
+	pipeline := &TypesettingPipeline{}
+	pipeline.SetTextShaper(fakeShaper{}, tc)
+	regs := parameters.NewTypesettingRegisters()
+	kh := KnotEncode(strings.NewReader("Hello World"), pipeline, regs)
+	cursor := NewCursor(kh)
+	for cursor.Next() && cursor.Knot().Type() != KTTextBox {
+	}
+	box := cursor.AsTextBox()
+	if box.Glyphs() == nil || box.Glyphs().GlyphCount() != 5 {
+		t.Fatalf("expected text box for 'Hello' to carry 5 glyphs")
+	}
+	if box.Width != 25*dimen.BP {
+		t.Errorf("expected text box for 'Hello' to be 25bp wide, is %.2fbp", box.Width.Points())
+	}
+	if box.Height <= 5*dimen.BP || box.Height > 10*dimen.BP || box.Depth > dimen.BP {
+		t.Errorf("expected 'Hello' to be 5…10bp high with hardly any depth, is %.2fbp+%.2fbp",
+			box.Height.Points(), box.Depth.Points())
+	}
+}
+
+func TestInterwordGlue(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	glues := func(kh *Khipu) []Glue {
+		var g []Glue
+		cursor := NewCursor(kh)
+		for cursor.Next() {
+			if cursor.Knot().Type() == KTGlue {
+				g = append(g, cursor.AsGlue())
+			}
+		}
+		return g
+	}
+	text := "Hello World. Go U.S. Army"
+	regs := parameters.NewTypesettingRegisters()
+	g := glues(KnotEncode(strings.NewReader(text), nil, regs))
+	t.Logf("glues = %v", g)
+	if len(g) != 4 {
+		t.Fatalf("expected 4 interword glues, have %d", len(g))
+	}
+	if g[0] != g[3] || g[0].W() != defaultSpace {
+		t.Errorf("expected normal interword glue, is %v and %v", g[0], g[3])
+	}
+	if g[1].W() <= g[0].W() || g[1].MaxW() <= g[0].MaxW() {
+		t.Errorf("expected wider glue at end of sentence, is %v", g[1])
+	}
+	regs.Push(parameters.P_FRENCHSPACING, true)
+	plain := KnotEncode(strings.NewReader(text), nil, regs)
+	g = glues(plain)
+	if g[1] != g[0] {
+		t.Errorf("expected normal glue at end of sentence for french spacing, is %v", g[1])
+	}
+	regs.Push(parameters.P_WORDSPACING, 2*dimen.BP)
+	regs.Push(parameters.P_LETTERSPACING, dimen.BP)
+	kh := KnotEncode(strings.NewReader(text), nil, regs)
+	if g2 := glues(kh); g2[0].W() != g[0].W()+2*dimen.BP {
+		t.Errorf("expected word spacing to widen interword glue, is %v", g2[0])
+	}
+	w0 := plain.knots[0].(*TextBox).Width
+	if w := kh.knots[0].(*TextBox).Width; w != w0+dimen.Dimen(len("Hello"))*dimen.BP {
+		t.Errorf("expected letter spacing to widen text box 'Hello' from %v by 5bp, is %v", w0, w)
+	}
+}
+
+func TestLetterSpacingDiscretionaries(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	var pipeline *TypesettingPipeline
+	kh := NewKhipu()
+	kh.AppendKnot(NewTextBox("Zu")).AppendKnot(pipeline.newSpellingDiscretionary("k", "k", "c"))
+	kh.AppendKnot(NewTextBox("ker")).AppendKnot(NewKnot(KTDiscretionary))
+	letterSpacing(kh, dimen.BP)
+	d := kh.knots[1].(Discretionary)
+	if d.Pre[0].W() != dimen.BP || d.Post[0].W() != dimen.BP || d.NoBreak[0].W() != dimen.BP {
+		t.Errorf("expected letter spacing to widen text of discretionary, is %+v", d)
+	}
+	if w := kh.knots[3].(Discretionary).PreBreak()[0].W(); w != 6*dimen.BP {
+		t.Errorf("expected letter spacing to widen hyphen to 6bp, is %v", w)
+	}
+	if w, _, _ := kh.Measure(0, -1); w != 6*dimen.BP {
+		t.Errorf("expected unbroken text 'Zucker' to be 6bp wide, is %v", w)
+	}
+}
+
+func TestEncodeParagraphs(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelInfo)
+	text := "The quick brown fox\njumps over the lazy dog.\n\n\nSecond paragraph.\n  \nThird one"
+	paragraphs, stop := EncodeParagraphs(strings.NewReader(text), nil, nil, 0)
+	defer stop()
+	var texts []string
+	for p := range paragraphs {
+		if p.Err != nil {
+			t.Fatal(p.Err)
+		}
+		if p.Number != len(texts)+1 {
+			t.Errorf("expected paragraph #%d, got #%d", len(texts)+1, p.Number)
+		}
+		texts = append(texts, p.Khipu.Text(0, p.Khipu.Length()))
+	}
+	if len(texts) != 3 || texts[0] != "The quick brown fox jumps over the lazy dog." {
+		t.Errorf("expected 3 paragraphs, got %q", texts)
+	}
+	paragraphs, stop = EncodeParagraphs(strings.NewReader(text), nil, nil, 0)
+	<-paragraphs
+	stop()
+	for range paragraphs { // encoder has to close the channel after stop
+	}
+}
+
+func TestInterCharacterBreaks(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh := NewKhipu().AppendKnot(NewTextBox("「日本語」の文章。"))
+	InterCharacterBreaks(kh, nil)
+	t.Logf("khipu = %s", kh)
+	var boxes []string
+	breaks := 0
+	cursor := NewCursor(kh)
+	for cursor.Next() {
+		switch cursor.Knot().Type() {
+		case KTTextBox:
+			boxes = append(boxes, cursor.AsTextBox().Text())
+		case KTPenalty:
+			if cursor.Knot().(Penalty) == 0 {
+				breaks++
+			}
+		}
+	}
+	if len(boxes) != 9 {
+		t.Fatalf("expected text to be split into 9 characters, is %v", boxes)
+	}
+	// no breaks after 「, before 」 and before 。
+	if breaks != 5 {
+		t.Errorf("expected 5 breakpoints respecting kinsoku rules, have %d", breaks)
+	}
+	pipeline := &TypesettingPipeline{}
+	pipeline.SetWordBreaker(unicode.Thai, NewDictionaryWordBreaker("สวัสดี", "ครับ"))
+	kh = NewKhipu().AppendKnot(NewTextBox("สวัสดีครับ"))
+	InterCharacterBreaks(kh, pipeline)
+	if kh.Length() != 4 || kh.Text(0, 1) != "สวัสดี" {
+		t.Errorf("expected Thai text to be split into 2 words, is %s", kh)
+	}
+}
+
+func TestHyphenationLimits(t *testing.T) {
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	syllables := applyHyphenMins([]string{"a", "bout", "ness", "es"}, 2, 3)
+	if strings.Join(syllables, "-") != "about-nesses" {
+		t.Errorf("expected about-nesses, is %v", syllables)
+	}
+	regs := parameters.NewTypesettingRegisters()
+	for _, word := range []string{"MP3", "UNESCO", "1920s"} {
+		if isHyphenatable(word, regs) {
+			t.Errorf("expected %q not to be hyphenatable", word)
+		}
+	}
+	if !isHyphenatable("Hyphenation", regs) {
+		t.Errorf("expected capitalized word to be hyphenatable")
+	}
+	regs.Push(parameters.P_HYPHENATEUPPERCASE, true)
+	if !isHyphenatable("UNESCO", regs) {
+		t.Errorf("expected uppercase word to be hyphenatable with P_HYPHENATEUPPERCASE")
+	}
+	for _, text := range []string{"https://example.com/hyphenation", "www.example.com", "info@example.com"} {
+		if !isURL(text) {
+			t.Errorf("expected %q to be recognized as URL", text)
+		}
+	}
+}
@@ -39,32 +39,44 @@ import (
 	"bufio"
 	"io"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/core/font"
+	"github.com/npillmayer/gotype/core/locate"
 	params "github.com/npillmayer/gotype/core/parameters"
 	"github.com/npillmayer/gotype/core/uax/segment"
 	"github.com/npillmayer/gotype/core/uax/uax14"
 	"github.com/npillmayer/gotype/core/uax/uax29"
-	"github.com/npillmayer/gotype/gtlocate"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
 	"golang.org/x/text/unicode/norm"
 )
 
 // A TypesettingPipeline consists of steps to produce a khipu from text.
 type TypesettingPipeline struct {
-	input       io.RuneReader
-	linewrap    *uax14.LineWrap
-	wordbreaker *uax29.WordBreaker
-	segmenter   *segment.Segmenter
-	words       *segment.Segmenter
+	input        io.RuneReader
+	linewrap     *uax14.LineWrap
+	wordbreaker  *uax29.WordBreaker
+	segmenter    *segment.Segmenter
+	words        *segment.Segmenter
+	shaper       textshaping.TextShaper // optional text shaper for text boxes
+	typecase     *font.TypeCase         // font to use with the text shaper
+	space        Glue                   // interword glue for the font
+	spacefactor  int                    // current space factor, see interwordGlue
+	wordbreakers []scriptWordBreaker    // dictionary word breakers, see SetWordBreaker
 }
 
-// KnotEncode transforms an input text into a khipu.
+// KnotEncode transforms an input text into a khipu. A snapshot of the
+// typesetting registers is attached to the khipu.
 func KnotEncode(text io.Reader, pipeline *TypesettingPipeline, regs *params.TypesettingRegisters) *Khipu {
 	if regs == nil {
 		regs = params.NewTypesettingRegisters()
 	}
 	pipeline = PrepareTypesettingPipeline(text, pipeline)
+	pipeline.spacefactor = normalSpaceFactor
 	khipu := NewKhipu()
+	khipu.SetRegisters(regs.Snapshot())
 	seg := pipeline.segmenter
 	for seg.Next() {
 		fragment := seg.Text()
@@ -73,8 +85,11 @@ func KnotEncode(text io.Reader, pipeline *TypesettingPipeline, regs *params.Type
 		if regs.N(params.P_MINHYPHENLENGTH) < dimen.Infty {
 			HyphenateTextBoxes(k, pipeline, regs)
 		}
+		ExplicitHyphens(k, pipeline)
 		khipu.AppendKhipu(k)
 	}
+	InterCharacterBreaks(khipu, pipeline)
+	letterSpacing(khipu, regs.D(params.P_LETTERSPACING))
 	CT().Infof("resulting khipu = %s", khipu)
 	return khipu
 }
@@ -90,21 +105,22 @@ func KnotEncode(text io.Reader, pipeline *TypesettingPipeline, regs *params.Type
 func createPartialKhipuFromSegment(seg *segment.Segmenter, pipeline *TypesettingPipeline, regs *params.TypesettingRegisters) *Khipu {
 	khipu := NewKhipu()
 	CT().Errorf("CREATE PARITAL KHIPU, PENALTIES=%v", seg.Penalties())
+	pipeline.spacefactor = spaceFactor(seg.Text(), pipeline.spacefactor)
 	if seg.Penalties()[0] < 1000 { // broken by primary breaker // TODO: this API is aweful...
 		// fragment is terminated by possible line wrap opportunity
 		if seg.Penalties()[1] < 1000 { // broken by secondary breaker, too
 			if seg.Penalties()[1] == segment.PenaltyAfterWhitespace {
-				g := NewGlue(5*dimen.BP, 1*dimen.BP, 2*dimen.BP)
+				g := pipeline.interwordGlue(regs)
 				p := Penalty(seg.Penalties()[1])
 				khipu.AppendKnot(g).AppendKnot(p)
 			} else {
-				b := NewTextBox(seg.Text())
+				b := pipeline.newTextBox(seg.Text())
 				p := Penalty(dimen.Infty)
 				khipu.AppendKnot(b).AppendKnot(p)
 			}
 		} else { // identified as a possible line break, but no space
 			// insert explicit discretionary '\-' penalty
-			b := NewTextBox(seg.Text())
+			b := pipeline.newTextBox(seg.Text())
 			p := Penalty(regs.N(params.P_HYPHENPENALTY))
 			khipu.AppendKnot(b).AppendKnot(p)
 		}
@@ -113,13 +129,13 @@ func createPartialKhipuFromSegment(seg *segment.Segmenter, pipeline *Typesetting
 		if seg.Penalties()[1] == segment.PenaltyBeforeWhitespace {
 			CT().Errorf("BROKEN BY SECONDARY BREAKER: TEXT_BOX")
 			// close a text box which is not a possible line wrap position
-			b := NewTextBox(seg.Text())
+			b := pipeline.newTextBox(seg.Text())
 			p := Penalty(dimen.Infty)
 			khipu.AppendKnot(b).AppendKnot(p)
 		} else {
 			CT().Errorf("BROKEN BY SECONDARY BREAKER: WHITESPACE")
 			// close a span of whitespace
-			g := NewGlue(5*dimen.PT, 1*dimen.PT, 2*dimen.PT)
+			g := pipeline.interwordGlue(regs)
 			p := Penalty(seg.Penalties()[1])
 			khipu.AppendKnot(g).AppendKnot(p)
 		}
@@ -131,12 +147,15 @@ func createPartialKhipuFromSegment(seg *segment.Segmenter, pipeline *Typesetting
 // Words are contained inside TextBox knots.
 //
 // Hyphenation is governed by the typesetting registers provided.
-// If regs is nil, no hyphenation is done.
+// If regs is nil, no hyphenation is done. For languages which change the
+// spelling of words at hyphenation points, non-standard discretionaries
+// are created (see SpellingChange).
 func HyphenateTextBoxes(khipu *Khipu, pipeline *TypesettingPipeline, regs *params.TypesettingRegisters) {
 	if regs == nil || khipu == nil {
 		return
 	}
 	k := make([]Knot, 0, khipu.Length())
+	spelling := spellingChangeFor(regs.S(params.P_LANGUAGE)) // may be nil
 	iterator := NewCursor(khipu)
 	for iterator.Next() {
 		if iterator.Knot().Type() != KTTextBox { // can only hyphenate text knots
@@ -145,27 +164,27 @@ func HyphenateTextBoxes(khipu *Khipu, pipeline *TypesettingPipeline, regs *param
 		}
 		CT().Debugf("knot = %v | %v", iterator.Knot(), iterator.Knot())
 		text := iterator.AsTextBox().text
+		if !regs.B(params.P_HYPHENATEURLS) && isURL(text) {
+			k = append(k, iterator.Knot())
+			continue
+		}
+		userHyphens := hasSoftHyphen(text) // user-specified hyphenation takes precedence
 		pipeline.words.Init(strings.NewReader(text))
 		for pipeline.words.Next() {
 			word := pipeline.words.Text()
 			CT().Debugf("   word = '%s'", word)
 			var syllables []string
 			isHyphenated := false
-			if len(word) >= regs.N(params.P_MINHYPHENLENGTH) {
+			if len(word) >= regs.N(params.P_MINHYPHENLENGTH) && !userHyphens {
 				if syllables, isHyphenated = HyphenateWord(word, regs); isHyphenated {
-					hyphen := NewKnot(KTDiscretionary)
-					for _, sy := range syllables[:len(syllables)-1] {
-						k = append(k, NewTextBox(sy))
-						k = append(k, hyphen)
-					}
-					k = append(k, NewTextBox(syllables[len(syllables)-1]))
+					k = append(k, pipeline.hyphenatedKnots(syllables, spelling)...)
 				}
 			}
 			if !isHyphenated {
 				if word == text {
 					k = append(k, iterator.Knot())
 				} else {
-					k = append(k, NewTextBox(word))
+					k = append(k, pipeline.newTextBox(word))
 				}
 			}
 		}
@@ -174,7 +193,9 @@ func HyphenateTextBoxes(khipu *Khipu, pipeline *TypesettingPipeline, regs *param
 }
 
 // PrepareTypesettingPipeline checks if a typesetting pipeline is correctly
-// initialized and creates a new one if is is invalid.
+// initialized and creates a new one if is is invalid. An existing pipeline
+// is reset to read from text, so pipelines may be re-used for more than one
+// paragraph.
 //
 // We use a uax14.LineWrapper as the primary breaker and
 // use a segment.SimpleWordBreaker to extract spans of whitespace.
@@ -189,22 +210,33 @@ func PrepareTypesettingPipeline(text io.Reader, pipeline *TypesettingPipeline) *
 	if pipeline.segmenter == nil {
 		pipeline.linewrap = uax14.NewLineWrap()
 		pipeline.segmenter = segment.NewSegmenter(pipeline.linewrap, segment.NewSimpleWordBreaker())
-		pipeline.segmenter.Init(pipeline.input)
 		pipeline.wordbreaker = uax29.NewWordBreaker()
 		pipeline.words = segment.NewSegmenter(pipeline.wordbreaker)
 	}
+	pipeline.segmenter.Init(pipeline.input)
 	return pipeline
 }
 
 // HyphenateWord hyphenates a single word.
+//
+// Hyphens are inserted only if at least P_LEFTHYPHENMIN characters precede them
+// and at least P_RIGHTHYPHENMIN characters follow them. Words containing digits
+// and words in uppercase (e.g., acronyms) are not hyphenated, unless
+// P_HYPHENATEDIGITS or P_HYPHENATEUPPERCASE, respectively, are set.
 func HyphenateWord(word string, regs *params.TypesettingRegisters) ([]string, bool) {
-	dict := gtlocate.Dictionary(regs.S(params.P_LANGUAGE))
-	ok := false
-	if dict == nil {
-		panic("TODO not yet implemented: find dictionnary for language")
+	if !isHyphenatable(word, regs) {
+		CT().Debugf("   will not hyphenate word '%s'", word)
+		return []string{word}, false
 	}
+	dict, err := locate.Dictionary(regs.S(params.P_LANGUAGE))
+	if err != nil {
+		CT().Errorf("cannot hyphenate: %v", err)
+		return []string{word}, false
+	}
+	ok := false
 	CT().Debugf("   will try to hyphenate word")
 	splitWord := dict.Hyphenate(word)
+	splitWord = applyHyphenMins(splitWord, regs.N(params.P_LEFTHYPHENMIN), regs.N(params.P_RIGHTHYPHENMIN))
 	if len(splitWord) > 1 {
 		ok = true
 	}
@@ -212,6 +244,45 @@ func HyphenateWord(word string, regs *params.TypesettingRegisters) ([]string, bo
 	return splitWord, ok
 }
 
+// isHyphenatable checks if a word may be hyphenated, with respect to digits and
+// uppercase letters.
+func isHyphenatable(word string, regs *params.TypesettingRegisters) bool {
+	letters, upper := 0, 0
+	for _, r := range word {
+		if unicode.IsDigit(r) && !regs.B(params.P_HYPHENATEDIGITS) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	return letters < 2 || upper < letters || regs.B(params.P_HYPHENATEUPPERCASE)
+}
+
+// applyHyphenMins joins syllables at the start and at the end of a word, such
+// that the first one has at least left and the last one at least right runes.
+func applyHyphenMins(syllables []string, left, right int) []string {
+	for len(syllables) > 1 && utf8.RuneCountInString(syllables[0]) < left {
+		syllables = append([]string{syllables[0] + syllables[1]}, syllables[2:]...)
+	}
+	for n := len(syllables); n > 1 && utf8.RuneCountInString(syllables[n-1]) < right; n = len(syllables) {
+		syllables = append(syllables[:n-2:n-2], syllables[n-2]+syllables[n-1])
+	}
+	return syllables
+}
+
+// isURL checks if a text looks like a URL or an e-mail address.
+func isURL(text string) bool {
+	if strings.Contains(text, "://") || strings.HasPrefix(text, "www.") {
+		return true
+	}
+	at := strings.IndexRune(text, '@')
+	return at > 0 && strings.ContainsRune(text[at:], '.')
+}
+
 /*
 func UAX14LineWrap(text string, regs *params.TypesettingRegisters) *Khipu {
 	sread := strings.NewReader(text) // wrap a reader around the CDATA string
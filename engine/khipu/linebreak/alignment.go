@@ -0,0 +1,106 @@
+package linebreak
+
+import (
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+)
+
+// Alignment is the horizontal alignment of the lines of a paragraph.
+type Alignment int8
+
+// Alignments for paragraphs. Unjustified text is set in the same way as TeX's
+// \raggedright, \raggedleft and \centering do: stretchable glue is inserted at
+// the edges of each line, as LeftSkip and RightSkip. As this glue is finitely
+// stretchable, line-breakers will still prefer line breaks which result in
+// lines of similar length.
+const (
+	Justified Alignment = iota
+	RaggedRight
+	RaggedLeft
+	Centered
+)
+
+func (a Alignment) String() string {
+	switch a {
+	case Justified:
+		return "justified"
+	case RaggedRight:
+		return "ragged-right"
+	case RaggedLeft:
+		return "ragged-left"
+	case Centered:
+		return "centered"
+	}
+	return "<unknown alignment>"
+}
+
+// AlignmentFromCSS returns the alignment for a CSS text-align value, as held
+// by typesetting register P_TEXTALIGN. Values "start" and "end" depend on the
+// direction of the text. Unknown values result in justified text.
+func AlignmentFromCSS(textalign string, rtl bool) Alignment {
+	switch textalign {
+	case "left":
+		return RaggedRight
+	case "right":
+		return RaggedLeft
+	case "center":
+		return Centered
+	case "start":
+		if rtl {
+			return RaggedLeft
+		}
+		return RaggedRight
+	case "end":
+		if rtl {
+			return RaggedRight
+		}
+		return RaggedLeft
+	}
+	return Justified
+}
+
+// AlignedParameters returns a copy of params with LeftSkip, RightSkip and
+// ParFillSkip set for an alignment. raggedness is the stretchability at a ragged
+// edge of a line, TeX uses 2em for \raggedright. For centered text, both edges
+// will stretch by raggedness.
+//
+// Interword glue is part of the khipu and remains unchanged. For text which
+// is not justified, clients may want to encode it with less stretchability.
+func AlignedParameters(params *Parameters, align Alignment, raggedness dimen.Dimen) *Parameters {
+	if params == nil {
+		params = DefaultParameters
+	}
+	p := *params
+	ragged := khipu.NewGlue(0, 0, raggedness)
+	switch align {
+	case Justified:
+		p.LeftSkip = khipu.NewGlue(0, 0, 0)
+		p.RightSkip = khipu.NewGlue(0, 0, 0)
+	case RaggedRight:
+		p.LeftSkip = khipu.NewGlue(0, 0, 0)
+		p.RightSkip = ragged
+	case RaggedLeft:
+		p.LeftSkip = ragged
+		p.RightSkip = khipu.NewGlue(0, 0, 0)
+		p.ParFillSkip = khipu.NewGlue(0, 0, 0) // last line is flush right as well
+	case Centered:
+		p.LeftSkip = ragged
+		p.RightSkip = ragged
+		p.ParFillSkip = khipu.NewGlue(0, 0, 0) // last line is centered as well
+	}
+	return &p
+}
+
+// Skips returns the glue to insert at the edges of lines, for packing lines
+// with khipu.HPack. Margin protrusion and font expansion are carried over, so
+// lines are packed to the widths the line breaker has measured.
+func (p *Parameters) Skips() khipu.Skips {
+	return khipu.Skips{
+		LeftSkip:    p.LeftSkip,
+		RightSkip:   p.RightSkip,
+		ParFillSkip: p.ParFillSkip,
+		Protrusion:  p.Protrusion,
+		FontStretch: p.FontStretch,
+		FontShrink:  p.FontShrink,
+	}
+}
@@ -0,0 +1,217 @@
+package linebreak
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+)
+
+// LineDiagnostics holds quality information for a single line of a broken
+// paragraph.
+type LineDiagnostics struct {
+	Line      int         // line number, starting at 1
+	From, To  int         // knot range [From…To) of the line's material
+	Length    dimen.Dimen // target length of the line, as given by the ParShape
+	Width     WSS         // natural, minimum and maximum width of the line
+	Badness   int32       // TeX-like badness, 0 <= b <= 10000
+	Overfull  dimen.Dimen // amount the line exceeds Length when shrunk maximally
+	Underfull bool        // badness of a stretched line exceeds HBadness
+	Reported  bool        // line exceeds the thresholds for reporting
+}
+
+func (ld LineDiagnostics) String() string {
+	if ld.Overfull > 0 {
+		return fmt.Sprintf("Overfull box (%.2fbp too wide) at line %d, knots %d…%d",
+			ld.Overfull.Points(), ld.Line, ld.From, ld.To)
+	}
+	if ld.Underfull {
+		return fmt.Sprintf("Underfull box (badness %d) at line %d, knots %d…%d",
+			ld.Badness, ld.Line, ld.From, ld.To)
+	}
+	return fmt.Sprintf("Line %d, knots %d…%d: badness %d", ld.Line, ld.From, ld.To, ld.Badness)
+}
+
+// Diagnostics is a list of line diagnostics for a paragraph.
+type Diagnostics []LineDiagnostics
+
+// Reported returns all lines exceeding the reporting thresholds, i.e. overfull
+// lines with an overshoot above HFuzz and underfull lines with a badness above
+// HBadness.
+func (d Diagnostics) Reported() Diagnostics {
+	var r Diagnostics
+	for _, ld := range d {
+		if ld.Reported {
+			r = append(r, ld)
+		}
+	}
+	return r
+}
+
+// HasOverfullLines returns true if at least one line is overfull beyond HFuzz.
+func (d Diagnostics) HasOverfullLines() bool {
+	for _, ld := range d {
+		if ld.Reported && ld.Overfull > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnose calculates quality information for the lines of a broken paragraph.
+// breakpoints are the result of a line-breaking algorithm, including the
+// pseudo-breakpoint at the start of the paragraph.
+// Discardable knots at the start and at the end of a line do not count as line
+// material. params.LeftSkip and params.RightSkip are added to every line,
+// params.ParFillSkip to the last one. As with the line breakers, characters at
+// the edges of a line protrude into the margins according to params.Protrusion,
+// and text boxes are subject to font expansion (see Parameters.KnotWSS).
+//
+// Lines are reported if they are overfull by more than params.HFuzz or if their
+// badness exceeds params.HBadness.
+func Diagnose(kh *khipu.Khipu, breakpoints []khipu.Mark, parshape ParShape,
+	params *Parameters) Diagnostics {
+	//
+	if kh == nil || parshape == nil || len(breakpoints) < 2 {
+		return nil
+	}
+	if params == nil {
+		params = DefaultParameters
+	}
+	diagnostics := make(Diagnostics, 0, len(breakpoints)-1)
+	cursor := khipu.NewCursor(kh) // walks the khipu once for all lines
+	for i := 1; i < len(breakpoints); i++ {
+		ld := LineDiagnostics{Line: i}
+		m := measureLine(cursor, breakpoints[i-1].Position()+1, breakpoints[i].Position(), params)
+		ld.From, ld.To, ld.Width = m.from, m.to, m.width
+		if d, ok := breakpoints[i-1].Knot().(khipu.Discretionary); ok && len(d.Post) > 0 {
+			ld.Width = ld.Width.Add(knotsWSS(d.Post, params))
+			first, last := edgeKnots(d.Post)
+			if first != nil {
+				m.first = first
+			}
+			if m.last == nil && m.from == m.to { // line consists of post-break knots only
+				m.last = last
+			}
+		}
+		if d, ok := breakpoints[i].Knot().(khipu.Discretionary); ok {
+			ld.Width = ld.Width.Add(knotsWSS(d.PreBreak(), params))
+			m.last = d
+		}
+		if m.first != nil {
+			p := params.Protrusion.LeftProtrusion(m.first)
+			ld.Width = ld.Width.Subtract(WSS{W: p, Min: p, Max: p})
+		}
+		if m.last != nil {
+			p := params.Protrusion.RightProtrusion(m.last)
+			ld.Width = ld.Width.Subtract(WSS{W: p, Min: p, Max: p})
+		}
+		ld.Width = ld.Width.Add(WSS{}.SetFromKnot(params.LeftSkip))
+		ld.Width = ld.Width.Add(WSS{}.SetFromKnot(params.RightSkip))
+		if i == len(breakpoints)-1 {
+			ld.Width = ld.Width.Add(WSS{}.SetFromKnot(params.ParFillSkip))
+		}
+		ld.Length = parshape.LineLength(i)
+		ld.Badness = Badness(ld.Width, ld.Length)
+		if ld.Width.Min > ld.Length {
+			ld.Overfull = ld.Width.Min - ld.Length
+			ld.Reported = ld.Overfull > params.HFuzz
+		} else if ld.Width.W < ld.Length && ld.Badness > params.HBadness {
+			ld.Underfull = true
+			ld.Reported = true
+		}
+		if ld.Reported {
+			T().Infof("%s", ld)
+		}
+		diagnostics = append(diagnostics, ld)
+	}
+	return diagnostics
+}
+
+// Badness calculates TeX's badness for a line with elastic width wss, which
+// is to be set to a given length. Badness is 0 for a perfect fit and
+// InfinityDemerits for lines which cannot be stretched or shrunk enough.
+func Badness(wss WSS, length dimen.Dimen) int32 {
+	var s, m float64
+	if wss.W < length {
+		s, m = float64(length-wss.W), float64(wss.Max-wss.W)
+	} else if wss.W > length {
+		s, m = float64(wss.W-length), float64(wss.W-wss.Min)
+	} else {
+		return 0
+	}
+	if s > m {
+		return InfinityDemerits
+	}
+	b := 100.0 * math.Pow(s/m, 3)
+	if b > float64(InfinityDemerits) {
+		return InfinityDemerits
+	}
+	return int32(b)
+}
+
+// lineMaterial is the material of a line, without discardable knots at its
+// start and at its end.
+type lineMaterial struct {
+	from, to    int        // knot range [from…to) of the material
+	width       WSS        // width of the material, including font expansion
+	first, last khipu.Knot // knots at the edges, for protrusion
+}
+
+// measureLine strips discardable knots from the start and the end of the
+// knot range [from…to) and measures the remaining material. The cursor is
+// advanced to position to, thus lines have to be measured in order.
+func measureLine(cursor *khipu.Cursor, from, to int, params *Parameters) lineMaterial {
+	m := lineMaterial{from: from, to: from}
+	var w WSS // width up to the current knot
+	for cursor.Next() && cursor.Position() < to {
+		if cursor.Position() < from {
+			continue
+		}
+		knot := cursor.Knot()
+		if m.to == from && knot.IsDiscardable() {
+			continue
+		}
+		w = w.Add(params.KnotWSS(knot))
+		if knot.IsDiscardable() {
+			continue
+		}
+		if m.to == from {
+			m.from = cursor.Position()
+		}
+		m.to, m.width = cursor.Position()+1, w
+		if khipu.IsEdgeKnot(knot) {
+			if m.first == nil {
+				m.first = knot
+			}
+			m.last = knot
+		}
+	}
+	if m.last != nil && m.last.Type() == khipu.KTDiscretionary { // not broken
+		m.last = nil
+	}
+	return m
+}
+
+// edgeKnots returns the first and the last edge knot (see khipu.IsEdgeKnot)
+// of a list of knots, or nil.
+func edgeKnots(knots []khipu.Knot) (first, last khipu.Knot) {
+	for _, knot := range knots {
+		if khipu.IsEdgeKnot(knot) {
+			if first == nil {
+				first = knot
+			}
+			last = knot
+		}
+	}
+	return first, last
+}
+
+func knotsWSS(knots []khipu.Knot, params *Parameters) WSS {
+	var w WSS
+	for _, knot := range knots {
+		w = w.Add(params.KnotWSS(knot))
+	}
+	return w
+}
@@ -0,0 +1,146 @@
+package linebreak
+
+import (
+	"testing"
+
+	"github.com/npillmayer/gotype/core/config/gtrace"
+	"github.com/npillmayer/gotype/core/config/tracing/gotestingadapter"
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+)
+
+func TestBadness(t *testing.T) {
+	wss := WSS{W: 90 * dimen.BP, Min: 90 * dimen.BP, Max: 100 * dimen.BP}
+	if b := Badness(wss, 100*dimen.BP); b != 100 {
+		t.Errorf("expected badness of fully stretched line to be 100, is %d", b)
+	}
+	if b := Badness(wss, 90*dimen.BP); b != 0 {
+		t.Errorf("expected badness of perfect fit to be 0, is %d", b)
+	}
+	if b := Badness(wss, 80*dimen.BP); b != InfinityDemerits {
+		t.Errorf("expected badness of overfull line to be infinite, is %d", b)
+	}
+}
+
+func TestDiagnoseOverfull(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh := khipu.NewKhipu()
+	word := func(w dimen.Dimen) *khipu.TextBox {
+		box := khipu.NewTextBox("x")
+		box.Width = w
+		return box
+	}
+	kh.AppendKnot(word(40 * dimen.BP)).AppendKnot(khipu.NewGlue(10*dimen.BP, 2*dimen.BP, 5*dimen.BP))
+	kh.AppendKnot(khipu.Penalty(0))
+	kh.AppendKnot(word(120 * dimen.BP)).AppendKnot(khipu.Penalty(-10000))
+	breakpoints := []khipu.Mark{startMark(-1)}
+	cursor := khipu.NewCursor(kh)
+	for cursor.Next() {
+		if cursor.Position() == 2 || cursor.Position() == 4 {
+			breakpoints = append(breakpoints, cursor.Mark())
+		}
+	}
+	parshape := RectangularParShape(100 * dimen.BP)
+	d := Diagnose(kh, breakpoints, parshape, DefaultParameters)
+	if len(d) != 2 {
+		t.Fatalf("expected diagnostics for 2 lines, have %d", len(d))
+	}
+	t.Logf("line 1: %v", d[0])
+	if d[0].From != 0 || d[0].To != 1 {
+		t.Errorf("expected line 1 to consist of knot 0, is %d…%d", d[0].From, d[0].To)
+	}
+	if !d[0].Underfull {
+		t.Errorf("expected line 1 to be underfull")
+	}
+	t.Logf("line 2: %v", d[1])
+	if d[1].Overfull != 20*dimen.BP || !d.HasOverfullLines() {
+		t.Errorf("expected line 2 to be overfull by 20bp, is %v", d[1].Overfull)
+	}
+	if len(d.Reported()) != 2 {
+		t.Errorf("expected 2 lines to be reported, have %d", len(d.Reported()))
+	}
+}
+
+func TestProtrudedLine(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	word := func(s string, w dimen.Dimen) *khipu.TextBox {
+		box := khipu.NewTextBox(s)
+		box.Width = w
+		return box
+	}
+	kh := khipu.NewKhipu()
+	kh.AppendKnot(word("Hello", 45*dimen.BP)).AppendKnot(khipu.NewGlue(10*dimen.BP, 2*dimen.BP, 5*dimen.BP))
+	kh.AppendKnot(word("world.", 60*dimen.BP)).AppendKnot(khipu.Penalty(-10000))
+	breakpoints := []khipu.Mark{startMark(-1)}
+	cursor := khipu.NewCursor(kh)
+	for cursor.Next() {
+		if cursor.Position() == 3 {
+			breakpoints = append(breakpoints, cursor.Mark())
+		}
+	}
+	params := *DefaultParameters
+	params.Protrusion = DefaultProtrusion() // the period protrudes by 7bp
+	parshape := RectangularParShape(107 * dimen.BP)
+	lines := khipu.HPack(kh, breakpoints, parshape, params.Skips())
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, have %d", len(lines))
+	}
+	l := lines[0]
+	t.Logf("line = %s", l)
+	if l.GlueSet <= -1.0 || l.Knots[len(l.Knots)-1].X != 107*dimen.BP {
+		t.Errorf("expected protruded line to pack to 107bp, is %s", l)
+	}
+	d := Diagnose(kh, breakpoints, parshape, &params)
+	t.Logf("line 1: %v", d[0])
+	if d[0].Overfull != 0 || len(d.Reported()) != 0 {
+		t.Errorf("expected protruded line to diagnose clean, is %v", d[0])
+	}
+	d = Diagnose(kh, breakpoints, parshape, DefaultParameters)
+	if !d.HasOverfullLines() {
+		t.Errorf("expected line without protrusion to be overfull")
+	}
+}
+
+func TestProtrusionSkipsWhatsits(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	quote, end := khipu.NewTextBox("“Quote"), khipu.NewTextBox("end.")
+	quote.Width, end.Width = 60*dimen.BP, 40*dimen.BP
+	kh := khipu.NewKhipu()
+	kh.AppendKnot(khipu.NewWhatsit("anchor", "start")).AppendKnot(quote)
+	kh.AppendKnot(khipu.NewGlue(10*dimen.BP, 2*dimen.BP, 5*dimen.BP)).AppendKnot(end)
+	kh.AppendKnot(khipu.NewWhatsit("anchor", "end")).AppendKnot(khipu.Penalty(-10000))
+	breakpoints := []khipu.Mark{startMark(-1)}
+	cursor := khipu.NewCursor(kh)
+	for cursor.Next() {
+		if cursor.Position() == 5 {
+			breakpoints = append(breakpoints, cursor.Mark())
+		}
+	}
+	params := *DefaultParameters
+	params.Protrusion = DefaultProtrusion() // “ protrudes by 5bp, . by 7bp
+	parshape := RectangularParShape(98 * dimen.BP)
+	lines := khipu.HPack(kh, breakpoints, parshape, params.Skips())
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, have %d", len(lines))
+	}
+	l := lines[0]
+	t.Logf("line = %s", l)
+	if l.GlueSet != 0 || l.Knots[3].X != -5*dimen.BP {
+		t.Errorf("expected characters next to whatsits to protrude by 12bp, line is %s", l)
+	}
+	d := Diagnose(kh, breakpoints, parshape, &params)
+	if d[0].Width.W != 98*dimen.BP || d[0].Badness != 0 {
+		t.Errorf("expected line to be diagnosed at 98bp, is %v", d[0])
+	}
+}
+
+type startMark int
+
+func (m startMark) Position() int    { return int(m) }
+func (m startMark) Knot() khipu.Knot { return khipu.Penalty(-10000) }
@@ -36,6 +36,9 @@ overshoots the line, and then has to move back to before that word.
 What counts as a word is not so clear with international scripts. We rely on the
 khipukamayuq to insert appropriate penalties before line-breaking happens.
 Thus, we do not break automatically on spaces, but rather on penalties.
+Discretionaries are not breakpoints for the first-fit algorithm. This holds
+for explicit discretionaries following a hyphen in the text as well, therefore
+neither HyphenPenalty nor ExHyphenPenalty apply.
 
 BSD License
 
@@ -127,6 +130,7 @@ func newLinebreaker(cursor linebreak.Cursor, parshape linebreak.ParShape,
 // new line.
 //
 // The input khipu has to end with a penalty.
+// For per-line quality information about the result, see linebreak.Diagnose.
 func BreakParagraph(cursor linebreak.Cursor, parshape linebreak.ParShape,
 	params *linebreak.Parameters) ([]khipu.Mark, error) {
 	//
@@ -147,13 +151,12 @@ func BreakParagraph(cursor linebreak.Cursor, parshape linebreak.ParShape,
 func (lb *linebreaker) FindBreakpoints() ([]khipu.Mark, error) {
 	breakpoints := make([]khipu.Mark, 1, 10)
 	breakpoints[0] = provisionalMark(-1) // first break is before first knot item
-	lineno := 0
 	spaceUsed := &segment{}
 	firstInLine := true
 	knot := lb.next() // we will iterate of every knot item in the khipu
 	last := lb.mark() // and remember the last one
 	for knot != nil {
-		linelen := lb.parshape.LineLength(lineno)
+		linelen := lb.parshape.LineLength(lb.linecount + 1) // line numbers start at 1
 		gtrace.CoreTracer.Debugf("_______________ %v ___________________", knot)
 		if knot.Type() == khipu.KTPenalty { // TODO discretionaries
 			last = lb.mark()
@@ -93,6 +93,11 @@ type wEdge struct {
 	cost      int32
 	total     int32
 	linecount int
+	badness   int32        // badness of the line represented by this edge
+	fitness   FitnessClass // fitness class of the line represented by this edge
+	// fitness class of the line ending at the start of this edge, which this
+	// edge continues
+	prevFitness FitnessClass
 }
 
 // nullEdge denotes an edge that is not present in a graph.
@@ -106,7 +111,8 @@ func (e wEdge) isNull() bool {
 // newWEdge returns a new weighted edge from one breakpoint to another,
 // given two breakpoints and a label-key.
 // It is not yet inserted into a graph.
-func newWEdge(from, to *feasibleBreakpoint, cost int32, total int32, linecnt int) wEdge {
+func newWEdge(from, to *feasibleBreakpoint, cost int32, total int32, linecnt int,
+	badness int32, fitness, prevFitness FitnessClass) wEdge {
 	if from.books[linecnt-1] == nil {
 		panic(fmt.Errorf("startpoint of new line %d seems to have incorrent books: %v", linecnt, from))
 	}
@@ -119,6 +125,10 @@ func newWEdge(from, to *feasibleBreakpoint, cost int32, total int32, linecnt int
 		cost:      cost,
 		total:     total,
 		linecount: linecnt,
+		badness:   badness,
+		fitness:   fitness,
+
+		prevFitness: prevFitness,
 	}
 }
 
@@ -225,7 +235,8 @@ func (g *fbGraph) RemoveEdge(from, to *feasibleBreakpoint, linecnt int) {
 // AddEdge adds a weighted edge from one node to another. Endpoints which are
 // not yet contained in the graph are added.
 // Does nothing if from=to.
-func (g *fbGraph) AddEdge(from, to *feasibleBreakpoint, cost int32, total int32, linecnt int) {
+func (g *fbGraph) AddEdge(from, to *feasibleBreakpoint, cost int32, total int32, linecnt int,
+	badness int32, fitness, prevFitness FitnessClass) {
 	if from.mark.Position() == to.mark.Position() {
 		return
 	}
@@ -233,7 +244,7 @@ func (g *fbGraph) AddEdge(from, to *feasibleBreakpoint, cost int32, total int32,
 		g.Add(to)
 	}
 	if g.Edge(from, to, linecnt).isNull() {
-		edge := newWEdge(from, to, cost, total, linecnt)
+		edge := newWEdge(from, to, cost, total, linecnt, badness, fitness, prevFitness)
 		if t, ok := g.edgesTo[to.mark.Position()]; ok {
 			edges := t[from.mark.Position()]
 			if edges == nil {
@@ -281,6 +292,35 @@ func (dict edgesDict) WithLabel(linecnt int) []wEdge {
 	return r
 }
 
+// EdgesWithLabel returns all edges to a breakpoint which are labelled with a
+// given linecount. If includePruned is true, deleted edges will be included.
+// For every start node at most one edge is returned, preferring edges which
+// have not been pruned.
+func (g *fbGraph) EdgesWithLabel(fb *feasibleBreakpoint, linecnt int, includePruned bool) []wEdge {
+	position := fb.mark.Position()
+	var r []wEdge
+	for from, edges := range g.edgesTo[position] {
+		if edge, ok := edges[linecnt]; ok {
+			r = append(r, edge)
+		} else if includePruned {
+			if edge, ok := g.prunedEdges[position][from][linecnt]; ok {
+				r = append(r, edge)
+			}
+		}
+	}
+	if includePruned {
+		for from, edges := range g.prunedEdges[position] {
+			if _, ok := g.edgesTo[position][from]; ok {
+				continue // already handled in the loop above
+			}
+			if edge, ok := edges[linecnt]; ok {
+				r = append(r, edge)
+			}
+		}
+	}
+	return r
+}
+
 // To returns all breakpoints in g that can reach directly to a breakpoint given by
 // a position. The returned breakpoints are sorted by position.
 func (g *fbGraph) To(fb *feasibleBreakpoint) []*feasibleBreakpoint {
@@ -41,6 +41,7 @@ func allEdges(kp *linebreaker, kh *khipu.Khipu, boxes map[int]*n, edgeT *templat
 		e.Cost = edge.cost
 		e.Total = edge.total
 		e.Line = edge.linecount
+		e.Fitness = edge.fitness.String()
 		start := 0
 		if edge.from >= 0 {
 			start = edge.from
@@ -113,6 +114,7 @@ type e struct {
 	N1, N2      *n
 	Cost, Total int32
 	Line        int
+	Fitness     string
 	Text        string
 	Color       string
 }
@@ -128,7 +130,7 @@ const boxTmpl = `{{.Name}}	[ label="{{.Text}}" shape=box style=filled fillcolor=
 `
 
 //const edgeTmpl = `{{ .N1.Name }} -> {{ .N2.Name }} [weight=1] ;
-const edgeTmpl = `{{.N1.Name}} -> {{.N2.Name}} [weight=1 label="{{.Cost}} of\n{{.Total}}\nline={{.Line}}" tooltip="“{{ .Text }}”" ] ;
+const edgeTmpl = `{{.N1.Name}} -> {{.N2.Name}} [weight=1 label="{{.Cost}} of\n{{.Total}}\nline={{.Line}}\n{{.Fitness}}" tooltip="“{{ .Text }}”" ] ;
 `
 
 // ----------------------------------------------------------------------
@@ -79,6 +79,9 @@ func NewKPDefaultParameters() *linebreak.Parameters {
 		ExHyphenPenalty:      50,
 		DoubleHyphenDemerits: 2000,
 		FinalHyphenDemerits:  10000,
+		AdjDemerits:          10000,
+		HBadness:             1000,
+		HFuzz:                dimen.Dimen(dimen.PT / 10),
 		EmergencyStretch:     dimen.Dimen(dimen.BP * 20),
 		LeftSkip:             khipu.NewGlue(0, 0, 0),
 		RightSkip:            khipu.NewGlue(0, 0, 0),
@@ -94,6 +97,7 @@ func setupLinebreaker(cursor linebreak.Cursor, parshape linebreak.ParShape,
 	kp := newLinebreaker(parshape, params)
 	fb := kp.newBreakpointAtMark(provisionalMark(-1)) // start of paragraph
 	fb.books[0] = &bookkeeping{}
+	fb.books[0].reach(DecentFit, 0)
 	kp.root = fb       // remember the start breakpoint as root of the graph
 	kp.horizon.Add(fb) // this is the first 'active node' of horizon
 	return kp, nil
@@ -142,22 +146,98 @@ func (h *activeFeasibleBreakpoints) next() *feasibleBreakpoint {
 // A break position may be selectable for different line counts, and we
 // retain all of them. Different line-count paths usually will have different costs.
 // We will hold some bookkeeping information to reflect active segments.
+//
+// As in TeX, for every line count we retain the cheapest line ending at the
+// breakpoint for each fitness class. A line which is slightly more expensive,
+// but compatible with the next line, may thus win against a cheaper line
+// charged with AdjDemerits later on.
 type feasibleBreakpoint struct {
 	mark  khipu.Mark           // location of this breakpoint
 	books map[int]*bookkeeping // bookkeeping per linecount
 }
 
 type bookkeeping struct {
-	segment      linebreak.WSS // sum of widths from this breakpoint up to current knot
-	totalcost    int32         // sum of costs for segment up to this breakpoint
-	startDiscard linebreak.WSS // sum of discardable space at start of segment / line
-	breakDiscard linebreak.WSS // sum of discardable space while lookinf for next breakpoint
-	hasContent   bool          // does this segment contain non-discardable item?
+	segment      linebreak.WSS         // sum of widths from this breakpoint up to current knot
+	totalcost    [fitnessClasses]int32 // sum of costs up to this breakpoint, per fitness class
+	reached      [fitnessClasses]bool  // fitness classes of lines ending at this breakpoint
+	startDiscard linebreak.WSS         // sum of discardable space at start of segment / line
+	breakDiscard linebreak.WSS         // sum of discardable space while lookinf for next breakpoint
+	hasContent   bool                  // does this segment contain non-discardable item?
+	first        khipu.Knot            // first edge knot of segment, for protrusion
+	last         khipu.Knot            // last edge knot of segment, for protrusion
+}
+
+// reach records a line of a fitness class ending at a breakpoint, with the total
+// cost of the paragraph up to the breakpoint.
+func (book *bookkeeping) reach(fitness FitnessClass, total int32) {
+	book.totalcost[fitness] = total
+	book.reached[fitness] = true
+}
+
+// cheapest returns the fitness class of the cheapest line ending at a breakpoint,
+// together with its total cost. If no line ends at the breakpoint, the fitness
+// class returned is -1.
+func (book *bookkeeping) cheapest() (FitnessClass, int32) {
+	fitness, total := FitnessClass(-1), int32(0)
+	for f, ok := range book.reached {
+		if ok && (fitness < 0 || book.totalcost[f] < total) {
+			fitness, total = FitnessClass(f), book.totalcost[f]
+		}
+	}
+	return fitness, total
 }
 
 type cost struct {
-	badness  int32 // 0 <= b <= 10000
-	demerits int32 // -10000 <= d <= 10000
+	badness  int32        // 0 <= b <= 10000
+	demerits int32        // -10000 <= d <= 10000
+	extra    int32        // demerits for hyphenated lines, not affecting feasibility
+	fitness  FitnessClass // fitness class of the line
+}
+
+// FitnessClass classifies lines by how much their glue has to stretch or
+// shrink. Adjacent lines with fitness classes differing by more than one
+// are visually incompatible and will be charged with additional demerits
+// (see linebreak.Parameters.AdjDemerits).
+type FitnessClass int8
+
+// Fitness classes, as defined by TeX.
+const (
+	VeryLooseFit FitnessClass = iota // glue stretches with badness > 99
+	LooseFit                         // glue stretches with 12 < badness <= 99
+	DecentFit                        // badness <= 12
+	TightFit                         // glue shrinks with badness > 12
+)
+
+const fitnessClasses = int(TightFit) + 1
+
+func (f FitnessClass) String() string {
+	switch f {
+	case VeryLooseFit:
+		return "very loose"
+	case LooseFit:
+		return "loose"
+	case DecentFit:
+		return "decent"
+	case TightFit:
+		return "tight"
+	}
+	return "fitness?"
+}
+
+// fitnessClass returns the fitness class for a line with badness b.
+func fitnessClass(b int32, shrinking bool) FitnessClass {
+	if shrinking {
+		if b > 12 {
+			return TightFit
+		}
+		return DecentFit
+	}
+	if b > 99 {
+		return VeryLooseFit
+	} else if b > 12 {
+		return LooseFit
+	}
+	return DecentFit
 }
 
 type provisionalMark int // provisional mark from an integer position
@@ -173,7 +253,8 @@ func (fb *feasibleBreakpoint) String() string {
 	b.WriteString(fmt.Sprintf("<fb %d/%v", fb.mark.Position(), fb.mark.Knot()))
 	b.WriteString("{")
 	for l, book := range fb.books {
-		b.WriteString(fmt.Sprintf(" %d:c=%d", l, book.totalcost))
+		_, total := book.cheapest()
+		b.WriteString(fmt.Sprintf(" %d:c=%d", l, total))
 	}
 	b.WriteString(" }>")
 	return b.String()
@@ -183,21 +264,19 @@ func (fb *feasibleBreakpoint) UpdateSegment(linecnt int, diff linebreak.WSS) {
 	if fb.books == nil {
 		fb.books = make(map[int]*bookkeeping)
 	}
-	segment := linebreak.WSS{}
-	total := int32(0)
 	book, ok := fb.books[linecnt]
-	if ok {
-		segment = book.segment
-		total = book.totalcost
+	if !ok {
+		book = &bookkeeping{}
 	}
 	fb.books[linecnt] = &bookkeeping{
-		segment:   segment.Add(diff),
-		totalcost: total,
+		segment:   book.segment.Add(diff),
+		totalcost: book.totalcost,
+		reached:   book.reached,
 	}
 }
 
-func (fb *feasibleBreakpoint) UpdateSegmentBookkeeping(mark khipu.Mark) {
-	wss := linebreak.WSS{}.SetFromKnot(mark.Knot()) // get dimensions of knot
+func (fb *feasibleBreakpoint) UpdateSegmentBookkeeping(mark khipu.Mark, params *linebreak.Parameters) {
+	wss := params.KnotWSS(mark.Knot()) // get dimensions of knot, including font expansion
 	for _, book := range fb.books {
 		book.segment = book.segment.Add(wss)
 		if book.hasContent {
@@ -213,6 +292,12 @@ func (fb *feasibleBreakpoint) UpdateSegmentBookkeeping(mark khipu.Mark) {
 				book.hasContent = true
 			}
 		}
+		if khipu.IsEdgeKnot(mark.Knot()) {
+			if book.first == nil {
+				book.first = mark.Knot()
+			}
+			book.last = mark.Knot()
+		}
 		T().Debugf("extending segment to %v", book.segment)
 	}
 }
@@ -242,90 +327,97 @@ func (kp *linebreaker) findBreakpointAtMark(mark khipu.Mark) *feasibleBreakpoint
 	return kp.Breakpoint(mark.Position()) // may be nil
 }
 
-func (kp *linebreaker) findPredecessorsWithLinecount(fb *feasibleBreakpoint, linecnt int) []*feasibleBreakpoint {
-	var predecessors []*feasibleBreakpoint
-	edges := kp.EdgesTo(fb).WithLabel(linecnt)
-	for _, edge := range edges {
-		if edge.isNull() {
-			panic("edge found but is null, this should not happen!") // TODO remove this after debugging
-		}
-		from := kp.StartOfEdge(edge)
-		if from == nil || from.books[linecnt-1] == nil {
-			T().Errorf("books of start node is %v", from.books)
-			panic(fmt.Sprintf("edge found, but start node seems broken: %v", from)) // TODO remove this after debugging
-		}
-		if edge.linecount == linecnt {
-			predecessors = append(predecessors, kp.StartOfEdge(edge))
+// findPredecessor finds the start of the line ending at fb, which is the
+// linecnt-th line and of a given fitness class. Returns nil if there is no such line.
+func (kp *linebreaker) findPredecessor(fb *feasibleBreakpoint, linecnt int,
+	fitness FitnessClass) (*feasibleBreakpoint, wEdge) {
+	//
+	for _, edge := range kp.EdgesTo(fb).WithLabel(linecnt) {
+		if edge.fitness == fitness {
+			return kp.StartOfEdge(edge), edge
 		}
 	}
-	return predecessors
+	return nil, nullEdge
 }
 
 // --- Segments ---------------------------------------------------------
 
 // newFeasibleLine possibly creates a segment between two given breakpoints.
 //
-// The segment is constructed and compared to
-// any existing segments (for the same line-count). If its cost is cheaper
-// than the exising one, the new segment replaces the old one
-// (just one segment between the two breakpoints can exist with pruning).
-func (kp *linebreaker) newFeasibleLine(fb *feasibleBreakpoint, mark khipu.Mark, cost int32,
+// The new line continues the line ending at fb which results in the least total
+// cost, taking into account demerits for visually incompatible lines.
+// The segment is constructed and compared to any existing segments (for the same
+// line-count and fitness class). If its cost is cheaper than the exising one,
+// the new segment replaces the old one (just one segment between the two
+// breakpoints can exist with pruning).
+func (kp *linebreaker) newFeasibleLine(fb *feasibleBreakpoint, mark khipu.Mark, c cost,
 	linecnt int) *feasibleBreakpoint {
 	//
 	newfb := kp.findBreakpointAtMark(mark)
 	if newfb == nil { // breakpoint not yet existent => create one
 		newfb = kp.newBreakpointAtMark(mark)
 	}
-	targettotal := fb.books[linecnt-1].totalcost + cost // total cost of new line
-	//T().Debugf("targettotal=%d, cost=%d", targettotal, cost)
-	if kp.isCheapestSurvivor(newfb, targettotal, linecnt) {
-		newfb.books[linecnt] = &bookkeeping{totalcost: targettotal}
-		kp.AddEdge(fb, newfb, cost, targettotal, linecnt)
-		T().Debugf("new line %v ---%d---> %v", fb, cost, newfb)
+	from := fb.books[linecnt-1]
+	prev, d := FitnessClass(-1), int32(0) // fitness class of line to continue, cost of new line
+	for f, ok := range from.reached {
+		if !ok {
+			continue
+		}
+		dd := c.demerits + c.extra + fitnessDemerits(FitnessClass(f), c.fitness, kp.params)
+		if prev < 0 || from.totalcost[f]+dd < from.totalcost[prev]+d {
+			prev, d = FitnessClass(f), dd
+		}
+	}
+	targettotal := from.totalcost[prev] + d // total cost of new line
+	//T().Debugf("targettotal=%d, cost=%d", targettotal, d)
+	if kp.isCheapestSurvivor(newfb, targettotal, linecnt, c.fitness) {
+		book, ok := newfb.books[linecnt]
+		if !ok {
+			book = &bookkeeping{}
+			if d, ok := mark.Knot().(khipu.Discretionary); ok && len(d.Post) > 0 {
+				// the new line starts with the post-break knots of the discretionary
+				book.segment = linebreak.WSS{}.SetFromKnots(d.Post)
+				book.hasContent = true
+				for _, knot := range d.Post {
+					if khipu.IsEdgeKnot(knot) {
+						if book.first == nil {
+							book.first = knot
+						}
+						book.last = knot
+					}
+				}
+			}
+			newfb.books[linecnt] = book
+		}
+		book.reach(c.fitness, targettotal)
+		kp.AddEdge(fb, newfb, d, targettotal, linecnt, c.badness, c.fitness, prev)
+		T().Debugf("new line %v ---%d---> %v", fb, d, newfb)
 	} else {
-		T().Debugf("not creating line %v ---%d---> %v", fb, cost, newfb)
+		T().Debugf("not creating line %v ---%d---> %v", fb, d, newfb)
 	}
 	return newfb
 }
 
-// isCheapestSurvivor calculates the total cost for a new segment, and compares it
-// to all existing segments. If the new segment would be cheaper,
-// the others will die.
+// isCheapestSurvivor compares the total cost for a new segment to the existing
+// segment ending at fb, for the same linecount and fitness class. If the new
+// segment would be cheaper, the existing one will die.
 func (kp *linebreaker) isCheapestSurvivor(fb *feasibleBreakpoint, totalcost int32,
-	linecnt int) bool {
-	//
-	var predecessor *feasibleBreakpoint          // predecessor breakpoint position
-	mintotal := linebreak.InfinityDemerits * 100 // pre-set to hyper-infinity
+	linecnt int, fitness FitnessClass) bool {
 	//
-	// Calculate an edge from fb to a new hypothetical breakpoint.
-	// If the total cost for the new edge would be cheaper than every existing
-	// edge, and deleteOthers is set, remove the more expensive edges.
-	T().Debugf("FB is %v, would produce line #%d", fb, linecnt)
-	if pp := kp.findPredecessorsWithLinecount(fb, linecnt); pp != nil {
-		T().Debugf("FB already has a predecessor for linecount=%d", linecnt)
-		if len(pp) > 1 { // TODO remove this after debugging
-			panic("breakpoint (with pruning) has more than one predecessor[line]")
-		}
-		predecessor = pp[0]
-		if predecessor.books[linecnt-1] == nil { // TODO remove this after debugging
-			// if predecessor.books[linecnt-1] != nil {
-			// 	T().Infof("predecessor has entry for linecount=%d", linecnt-1)
-			// }
-			panic(fmt.Sprintf("predecessor breakpoint has no entry for linecount=%d", linecnt))
-		}
-		// isolate total cost of predecessor for segment to fb
-		predCost := kp.Edge(predecessor, fb, linecnt).cost           // cost of pred--->fb
-		mintotal = predecessor.books[linecnt-1].totalcost + predCost // totalcost via pred to fb
-	}
-	//T().Debugf("mintotal=%d, totalcost=%d", mintotal, totalcost)
-	if totalcost < mintotal { // new line is cheaper
-		if predecessor != nil {
-			T().Debugf("new FB is cheaper than existing %v--->%v, remove it", predecessor, fb)
-			kp.RemoveEdge(predecessor, fb, linecnt)
-		}
+	T().Debugf("FB is %v, would produce line #%d (%v)", fb, linecnt, fitness)
+	book, ok := fb.books[linecnt]
+	if !ok || !book.reached[fitness] {
 		return true
 	}
-	return false // some older edge to fb is cheaper than new one
+	T().Debugf("FB already has a predecessor for linecount=%d", linecnt)
+	if totalcost >= book.totalcost[fitness] {
+		return false // some older edge to fb is cheaper than new one
+	}
+	if predecessor, _ := kp.findPredecessor(fb, linecnt, fitness); predecessor != nil {
+		T().Debugf("new FB is cheaper than existing %v--->%v, remove it", predecessor, fb)
+		kp.RemoveEdge(predecessor, fb, linecnt)
+	}
+	return true
 }
 
 // === Algorithms ============================================================
@@ -333,8 +425,12 @@ func (kp *linebreaker) isCheapestSurvivor(fb *feasibleBreakpoint, totalcost int3
 // Calculate the cost of a breakpoint. A breakpoint may result either in being
 // infeasible (demerits >= infinity) or having a positive (demerits) or negative
 // (merits) cost/benefit.
-func (fb *feasibleBreakpoint) calculateCostsTo(penalty khipu.Penalty, parshape linebreak.ParShape,
-	params *linebreak.Parameters) (map[int]cost, bool) {
+//
+// Flag hyphenated signals that the new breakpoint is located at a hyphen, flag final
+// that it is the end of the paragraph. Both are needed for charging
+// demerits for consecutive hyphenated lines.
+func (fb *feasibleBreakpoint) calculateCostsTo(penalty khipu.Penalty, hyphenated bool, final bool,
+	parshape linebreak.ParShape, params *linebreak.Parameters) (map[int]cost, bool) {
 	//
 	T().Debugf("### calculateCostsTo(%v)", penalty)
 	var costs = make(map[int]cost) // linecount => cost, i.e. costs for different line targets
@@ -342,16 +438,19 @@ func (fb *feasibleBreakpoint) calculateCostsTo(penalty khipu.Penalty, parshape l
 	for linecnt := range fb.books {
 		T().Debugf(" ## checking cost at linecnt=%d", linecnt)
 		linelen := parshape.LineLength(linecnt + 1) // length of line to fit into
-		segwss := fb.segmentWidth(linecnt, params)
+		segwss := fb.segmentWidth(linecnt, hyphenated, params)
 		d := linebreak.InfinityDemerits  // pre-set result variable
 		b := linebreak.InfinityDemerits  // badness of line
+		var extra int32                  // demerits from adjacent line
+		fitness := TightFit              // fitness class of line
 		stsh := absD(linelen - segwss.W) // stretch or shrink of glue in line
 		T().Debugf("    +---%.2f--->    | %.2f", segwss.W.Points(), linelen.Points())
 		if segwss.Min > linelen { // segment cannot shrink enough
 			cannotReachIt++
 		} else {
 			d, b = calculateDemerits(segwss, stsh, penalty, params)
-
+			fitness = fitnessClass(b, segwss.W > linelen)
+			extra = fb.hyphenDemerits(hyphenated, final, params)
 		}
 		/*
 			if segwss.W <= linelen { // natural width less than line-length
@@ -373,27 +472,77 @@ func (fb *feasibleBreakpoint) calculateCostsTo(penalty khipu.Penalty, parshape l
 				}
 			}
 		*/
-		T().Debugf(" ## cost for line %d (b=%d) would be %s, penalty %v", linecnt+1, b,
-			demeritsString(d), penalty)
-		costs[linecnt] = cost{demerits: d, badness: b}
+		T().Debugf(" ## cost for line %d (b=%d, %v) would be %s+%d, penalty %v", linecnt+1, b,
+			fitness, demeritsString(d), extra, penalty)
+		costs[linecnt] = cost{demerits: d, badness: b, extra: extra, fitness: fitness}
 	}
 	stillreachable := (cannotReachIt < len(fb.books))
 	T().Debugf("### costs to %v is %v, reachable is %v", penalty, costs, stillreachable)
 	return costs, stillreachable
 }
 
+// hyphenDemerits calculates additional demerits for a new line starting at fb,
+// if the line ending at fb is hyphenated: consecutive hyphenated lines are charged
+// with params.DoubleHyphenDemerits, and a hyphen in the second-to-last line with
+// params.FinalHyphenDemerits.
+func (fb *feasibleBreakpoint) hyphenDemerits(hyphenated bool, final bool,
+	params *linebreak.Parameters) int32 {
+	//
+	if fb.isHyphenated() {
+		if final {
+			return params.FinalHyphenDemerits
+		} else if hyphenated {
+			return params.DoubleHyphenDemerits
+		}
+	}
+	return 0
+}
+
+// fitnessDemerits returns params.AdjDemerits for visually incompatible adjacent
+// lines, i.e. lines with fitness classes differing by more than one.
+func fitnessDemerits(prev, fitness FitnessClass, params *linebreak.Parameters) int32 {
+	if abs(int32(prev)-int32(fitness)) > 1 {
+		return params.AdjDemerits
+	}
+	return 0
+}
+
+// isHyphenated returns true if this breakpoint is located at a hyphen.
+func (fb *feasibleBreakpoint) isHyphenated() bool {
+	if fb.mark == nil || fb.mark.Position() < 0 {
+		return false
+	}
+	return fb.mark.Knot().Type() == khipu.KTDiscretionary
+}
+
 // segmentWidth returns the widths of a segment at fb, subtracting discardable
-// items at the start of the segment and at the end (= possible breakpoint).
+// items at the start of the segment and at the end (= possible breakpoint),
+// and adding params.LeftSkip & RightSkip.
 //
-// TODO This is the location to use params.LeftSkip & RightSkip
-func (fb *feasibleBreakpoint) segmentWidth(linecnt int, params *linebreak.Parameters) linebreak.WSS {
-	segw := fb.books[linecnt].segment
-	segw = segw.Subtract(fb.books[linecnt].startDiscard)
-	segw = segw.Subtract(fb.books[linecnt].breakDiscard)
+// Characters at the edges of the segment may protrude into the margins (see
+// linebreak.Protrusion), which reduces the width of the segment. Flag hyphenated
+// signals that the segment ends with a hyphen. For a segment ending at a
+// discretionary, the pre-break knots replace the no-break knots.
+func (fb *feasibleBreakpoint) segmentWidth(linecnt int, hyphenated bool, params *linebreak.Parameters) linebreak.WSS {
+	book := fb.books[linecnt]
+	segw := book.segment
+	segw = segw.Subtract(book.startDiscard)
+	segw = segw.Subtract(book.breakDiscard)
+	if d, ok := book.last.(khipu.Discretionary); ok && hyphenated {
+		segw = segw.Subtract(linebreak.WSS{}.SetFromKnot(d))
+		segw = segw.Add(linebreak.WSS{}.SetFromKnots(d.PreBreak()))
+	}
 	w := linebreak.WSS{}.SetFromKnot(params.LeftSkip)
 	segw = segw.Add(w)
 	w = linebreak.WSS{}.SetFromKnot(params.RightSkip)
 	segw = segw.Add(w)
+	if params.Protrusion != nil && book.first != nil {
+		p := params.Protrusion.LeftProtrusion(book.first)
+		if book.last.Type() != khipu.KTDiscretionary || hyphenated {
+			p += params.Protrusion.RightProtrusion(book.last)
+		}
+		segw = segw.Subtract(linebreak.WSS{W: p, Min: p, Max: p})
+	}
 	return segw
 }
 
@@ -425,6 +574,11 @@ func calculateDemerits(segwss linebreak.WSS, stretch dimen.Dimen, penalty khipu.
 	return d, badness
 }
 
+// isBreakpoint returns true if a knot is a legal breakpoint.
+func isBreakpoint(knot khipu.Knot) bool {
+	return knot.Type() == khipu.KTPenalty || knot.Type() == khipu.KTDiscretionary
+}
+
 func demeritsString(d int32) string {
 	if d >= linebreak.InfinityDemerits {
 		return "\u221e"
@@ -481,6 +635,7 @@ func penaltyAt(cursor linebreak.Cursor) (khipu.Penalty, khipu.Mark) {
 // optimal, and BreakParagraph will return that.
 //
 // For a function to get solutions with different linecounts, see FindBreakpoints.
+// For per-line quality information about the result, see linebreak.Diagnose.
 func BreakParagraph(cursor linebreak.Cursor, parshape linebreak.ParShape,
 	params *linebreak.Parameters) ([]khipu.Mark, error) {
 	//
@@ -513,15 +668,10 @@ func BreakParagraph(cursor linebreak.Cursor, parshape linebreak.ParShape,
 func FindBreakpoints(cursor linebreak.Cursor, parshape linebreak.ParShape, params *linebreak.Parameters,
 	dotfile io.Writer) ([]int, map[int][]khipu.Mark, error) {
 	//
-	kp, err := setupLinebreaker(cursor, parshape, params)
+	kp, err := breakpointGraph(cursor, parshape, params)
 	if err != nil {
 		return nil, nil, err
 	}
-	err = kp.constructBreakpointGraph(cursor, parshape, params)
-	if err != nil {
-		T().Errorf(err.Error())
-		return nil, nil, err
-	}
 	variants, breaks := kp.collectFeasibleBreakpoints(kp.end)
 	if dotfile != nil {
 		dotcursor := khipu.NewCursor(cursor.Khipu())
@@ -530,6 +680,22 @@ func FindBreakpoints(cursor linebreak.Cursor, parshape linebreak.ParShape, param
 	return variants, breaks, nil
 }
 
+// breakpointGraph sets up a linebreaker and lets it construct the graph of
+// feasible breakpoints for a paragraph.
+func breakpointGraph(cursor linebreak.Cursor, parshape linebreak.ParShape,
+	params *linebreak.Parameters) (*linebreaker, error) {
+	//
+	kp, err := setupLinebreaker(cursor, parshape, params)
+	if err != nil {
+		return nil, err
+	}
+	if err = kp.constructBreakpointGraph(cursor, parshape, params); err != nil {
+		T().Errorf(err.Error())
+		return nil, err
+	}
+	return kp, nil
+}
+
 // constructBreakpointGraph is the central algorithm, akin to the paragraph breaking
 // algorithm described by Knuth & Plass for the TeX typesetting system.
 //
@@ -562,24 +728,34 @@ func (kp *linebreaker) constructBreakpointGraph(cursor linebreak.Cursor, parshap
 		// --- main loop over active breakpoints in horizon ------------
 		for fb != nil { // loop over active feasible breakpoints of horizon
 			T().Debugf("                %d/%v  (in horizon)", fb.mark.Position(), fb.mark.Knot())
-			fb.UpdateSegmentBookkeeping(cursor.Mark())
-			// Breakpoints are allowed at penalties only
-			if cursor.Mark().Knot().Type() == khipu.KTPenalty { // TODO discretionaries
+			fb.UpdateSegmentBookkeeping(cursor.Mark(), kp.params)
+			// Breakpoints are allowed at penalties and discretionaries only
+			if isBreakpoint(cursor.Mark().Knot()) {
 				var penalty khipu.Penalty
-				penalty, last = penaltyAt(cursor) // find correct p, if more than one
-				costs, stillreachable := fb.calculateCostsTo(penalty, parshape, kp.params)
+				hyphenated := cursor.Mark().Knot().Type() == khipu.KTDiscretionary
+				if hyphenated {
+					penalty = khipu.Penalty(kp.params.HyphenPenalty)
+					if cursor.Mark().Knot().(khipu.Discretionary).IsExplicit() {
+						penalty = khipu.Penalty(kp.params.ExHyphenPenalty)
+					}
+				} else {
+					penalty, last = penaltyAt(cursor) // find correct p, if more than one
+				}
+				_, more := cursor.Peek()
+				final := !more && penalty.Demerits() <= linebreak.InfinityMerits
+				costs, stillreachable := fb.calculateCostsTo(penalty, hyphenated, final, parshape, kp.params)
 				if stillreachable { // yes, position may have been reached in this iteration
 					for linecnt, cost := range costs { // check for every linecount alternative
 						if penalty.Demerits() <= linebreak.InfinityMerits { // forced break
 							if cost.badness > kp.params.Tolerance {
 								T().Infof("Underfull box at line %d, b=%d, d=%d", linecnt+1, cost.badness, cost.demerits)
 							}
-							newfb := kp.newFeasibleLine(fb, cursor.Mark(), cost.demerits, linecnt+1)
+							newfb := kp.newFeasibleLine(fb, cursor.Mark(), cost, linecnt+1)
 							kp.horizon.Add(newfb) // make forced break member of horizon n+1
 						} else if cost.badness < kp.params.Tolerance &&
 							cost.demerits < linebreak.InfinityDemerits { // happy case: new breakpoint is feasible
 							//
-							newfb := kp.newFeasibleLine(fb, cursor.Mark(), cost.demerits, linecnt+1)
+							newfb := kp.newFeasibleLine(fb, cursor.Mark(), cost, linecnt+1)
 							kp.horizon.Add(newfb) // make new breakpoint member of horizon n+1
 						}
 					}
@@ -587,7 +763,12 @@ func (kp *linebreaker) constructBreakpointGraph(cursor linebreak.Cursor, parshap
 					if kp.horizon.Size() <= 1 { // oops, low on options
 						for linecnt := range costs {
 							T().Infof("Overfull box at line %d, cost=10000", linecnt+1)
-							newfb := kp.newFeasibleLine(fb, cursor.Mark(), linebreak.InfinityDemerits, linecnt+1)
+							overfull := cost{
+								badness:  linebreak.InfinityDemerits,
+								demerits: linebreak.InfinityDemerits,
+								fitness:  TightFit,
+							}
+							newfb := kp.newFeasibleLine(fb, cursor.Mark(), overfull, linecnt+1)
 							kp.horizon.Add(newfb) // make new fb member of horizon n+1
 							if newfb.mark.Position() == fb.mark.Position() {
 								panic("THIS SHOULD NOT HAPPEN ?!?")
@@ -619,10 +800,14 @@ func (kp *linebreaker) collectFeasibleBreakpoints(last *feasibleBreakpoint) (
 	costDict := make(map[int]int32)                 // list of total-costs per linecount-variant
 	lineVariants := make([]int, 0, len(last.books)) // will become sorted list of linecount-variants
 	for linecnt, book := range last.books {
-		costDict[linecnt] = book.totalcost
+		fitness, total := book.cheapest()
+		if fitness < 0 {
+			continue
+		}
+		costDict[linecnt] = total
 		i := len(lineVariants)
 		for j, c := range lineVariants {
-			if book.totalcost < costDict[c] {
+			if total < costDict[c] {
 				i = j
 				break
 			}
@@ -630,16 +815,14 @@ func (kp *linebreaker) collectFeasibleBreakpoints(last *feasibleBreakpoint) (
 		lineVariants = insert(lineVariants, i, linecnt)
 		breaks := make([]khipu.Mark, 0, 20)
 		breaks = append(breaks, last.mark)
-		l := linecnt
-		predecessors := kp.findPredecessorsWithLinecount(last, l)
-		for len(predecessors) > 0 { // while not at start node
-			l-- // searching for predecessor with linecount-1
-			if len(predecessors) > 1 {
-				panic("THERE SHOULD ONLY BE ONE PREDECESSOR") // TODO remove after debugging
+		fb := last
+		for l := linecnt; l > 0; l-- { // walk back to start node
+			pred, edge := kp.findPredecessor(fb, l, fitness)
+			if pred == nil {
+				panic(fmt.Sprintf("no predecessor for line %d at %v", l, fb)) // TODO remove after debugging
 			}
-			pred := predecessors[0]
 			breaks = append(breaks, pred.mark)
-			predecessors = kp.findPredecessorsWithLinecount(pred, l)
+			fb, fitness = pred, edge.prevFitness
 		}
 		T().Debugf("reversing the breakpoint list for line %d: %v", linecnt, breaks)
 		for i := len(breaks)/2 - 1; i >= 0; i-- { // exchange b[i] with opposite
@@ -187,6 +187,206 @@ func TestKPParaPrincess(t *testing.T) {
 	}
 }
 
+func TestKPAlternatives(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh, _, _ := setupKPTest(t, princess, false)
+	cursor := linebreak.NewFixedWidthCursor(khipu.NewCursor(kh), 10*dimen.BP, 2)
+	parshape := linebreak.RectangularParShape(45 * 10 * dimen.BP)
+	v, alternatives, err := FindAlternatives(cursor, parshape, NewKPDefaultParameters(), 3)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, linecnt := range v {
+		solutions := alternatives[linecnt]
+		if len(solutions) == 0 || len(solutions) > 3 {
+			t.Errorf("expected 1…3 alternatives for %d lines, have %d", linecnt, len(solutions))
+		}
+		for i, s := range solutions {
+			t.Logf("%d lines, alternative #%d: d=%d", linecnt, i, s.Demerits)
+			if len(s.Breakpoints) != linecnt+1 {
+				t.Errorf("expected %d breakpoints for %d lines, have %d", linecnt+1, linecnt,
+					len(s.Breakpoints))
+			}
+			if len(s.Fitness) != linecnt {
+				t.Errorf("expected fitness classes for %d lines, have %d", linecnt, len(s.Fitness))
+			}
+			if i > 0 && s.Demerits < solutions[i-1].Demerits {
+				t.Errorf("alternatives for %d lines not sorted by demerits", linecnt)
+			}
+		}
+	}
+}
+
+func TestKPFitness(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	if f := fitnessClass(5, false); f != DecentFit {
+		t.Errorf("expected b=5 to be decent, is %v", f)
+	}
+	if f := fitnessClass(50, false); f != LooseFit {
+		t.Errorf("expected stretched b=50 to be loose, is %v", f)
+	}
+	if f := fitnessClass(50, true); f != TightFit {
+		t.Errorf("expected shrunk b=50 to be tight, is %v", f)
+	}
+	params := NewKPDefaultParameters()
+	if d := fitnessDemerits(TightFit, VeryLooseFit, params); d != params.AdjDemerits {
+		t.Errorf("expected tight line followed by very loose line to cost %d, is %d",
+			params.AdjDemerits, d)
+	}
+	if d := fitnessDemerits(TightFit, DecentFit, params); d != 0 {
+		t.Errorf("expected tight line followed by decent line to cost 0, is %d", d)
+	}
+}
+
+func TestKPFitnessPredecessor(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	params := NewKPDefaultParameters()
+	kp, _ := setupLinebreaker(nil, linebreak.RectangularParShape(100*dimen.BP), params)
+	line := func(from *feasibleBreakpoint, to int, d int32, f FitnessClass, linecnt int) *feasibleBreakpoint {
+		return kp.newFeasibleLine(from, provisionalMark(to), cost{demerits: d, fitness: f}, linecnt)
+	}
+	// two ways to reach breakpoint 3 with 2 lines: a cheap one, ending with a
+	// very loose line, and a dearer one, ending with a decent line
+	a := line(kp.root, 1, 100, LooseFit, 1)
+	b := line(kp.root, 2, 100, DecentFit, 1)
+	x := line(a, 3, 100, VeryLooseFit, 2)
+	line(b, 3, 150, DecentFit, 2)
+	// a tight last line is incompatible with the very loose line
+	end := line(x, 4, 100, TightFit, 3)
+	variants, breakpoints := kp.collectFeasibleBreakpoints(end)
+	if len(variants) != 1 || variants[0] != 3 {
+		t.Fatalf("expected a single solution with 3 lines, got %v", variants)
+	}
+	if _, total := end.books[3].cheapest(); total != 350 {
+		t.Errorf("expected total demerits of 350 without AdjDemerits, are %d", total)
+	}
+	if breaks := breakpoints[3]; len(breaks) != 4 || breaks[1].Position() != 2 {
+		t.Errorf("expected solution to break at 2 (compatible predecessor), is %v", breaks)
+	}
+	alternatives := kp.collectAlternatives(end, []int{3}, 2)[3]
+	if len(alternatives) != 2 || alternatives[0].Demerits != 350 ||
+		alternatives[1].Demerits != 300+params.AdjDemerits {
+		t.Errorf("expected alternatives with 350 and %d demerits, are %v", 300+params.AdjDemerits, alternatives)
+	}
+}
+
+func TestKPLooseness(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh, _, _ := setupKPTest(t, princess, false)
+	cursor := linebreak.NewFixedWidthCursor(khipu.NewCursor(kh), 10*dimen.BP, 2)
+	parshape := linebreak.RectangularParShape(45 * 10 * dimen.BP)
+	optimal, err := BreakParagraph(cursor, parshape, NewKPDefaultParameters())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	kh, _, _ = setupKPTest(t, princess, false)
+	cursor = linebreak.NewFixedWidthCursor(khipu.NewCursor(kh), 10*dimen.BP, 2)
+	loose, err := BreakParagraphWithLooseness(cursor, parshape, NewKPDefaultParameters(), 1,
+		linebreak.InfinityDemerits*100)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Logf("optimal paragraph has %d lines, loose one has %d", len(optimal)-1, loose.Linecount)
+	if loose.Linecount != len(optimal) { // optimal includes the start of the paragraph
+		t.Errorf("expected loose paragraph to have %d lines, has %d", len(optimal), loose.Linecount)
+	}
+	if samePositions(loose.Breakpoints, optimal) {
+		t.Errorf("expected loose paragraph to break differently from optimal one")
+	}
+	if n := len(loose.Breakpoints); n != loose.Linecount+1 ||
+		loose.Breakpoints[n-1].Position() != optimal[len(optimal)-1].Position() {
+		t.Errorf("expected loose paragraph to end at %d, breakpoints are %v",
+			optimal[len(optimal)-1].Position(), loose.Breakpoints)
+	}
+}
+
+func samePositions(b1, b2 []khipu.Mark) bool {
+	if len(b1) != len(b2) {
+		return false
+	}
+	for i := range b1 {
+		if b1[i].Position() != b2[i].Position() {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKPPasses(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh, _, _ := setupKPTest(t, princess, false)
+	cursor := func(kh *khipu.Khipu) linebreak.Cursor {
+		return linebreak.NewFixedWidthCursor(khipu.NewCursor(kh), 10*dimen.BP, 2)
+	}
+	parshape := linebreak.RectangularParShape(45 * 10 * dimen.BP)
+	params := NewKPDefaultParameters()
+	params.PreTolerance = 5000
+	solution, pass, _, err := BreakParagraphInPasses(kh, cursor, parshape, params, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Logf("%v found solution with %d lines", pass, solution.Linecount)
+	if pass != FirstPass {
+		t.Errorf("expected first pass to succeed with loose pre-tolerance, %v did", pass)
+	}
+	kh, _, _ = setupKPTest(t, princess, true)
+	solution, pass, _, err = BreakParagraphInPasses(kh, cursor, parshape, params, nil, nil)
+	if err != nil || pass != FirstPass {
+		t.Fatalf("expected first pass to succeed for hyphenated paragraph, %v did", pass)
+	}
+	for _, mark := range solution.Breakpoints[1:] {
+		if mark.Knot().Type() == khipu.KTDiscretionary {
+			t.Errorf("expected first pass not to break at discretionary %d", mark.Position())
+		}
+	}
+	kh, _, _ = setupKPTest(t, princess, false)
+	params.PreTolerance = -1
+	solution, pass, _, err = BreakParagraphInPasses(kh, cursor, parshape, params, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Logf("%v found solution with %d lines", pass, solution.Linecount)
+	if pass != SecondPass {
+		t.Errorf("expected second pass to succeed for negative pre-tolerance, %v did", pass)
+	}
+}
+
+func TestKPEmergencyPass(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	kh, _, _ := setupKPTest(t, princess, false)
+	cursor := func(kh *khipu.Khipu) linebreak.Cursor {
+		return linebreak.NewFixedWidthCursor(khipu.NewCursor(kh), 10*dimen.BP, 2)
+	}
+	parshape := linebreak.RectangularParShape(45 * 10 * dimen.BP)
+	params := NewKPDefaultParameters()
+	params.PreTolerance = -1
+	params.Tolerance = 10
+	params.EmergencyStretch = 100 * dimen.BP
+	solution, pass, effective, err := BreakParagraphInPasses(kh, cursor, parshape, params, nil, nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Logf("%v found solution with %d lines", pass, solution.Linecount)
+	if pass != EmergencyPass {
+		t.Errorf("expected only the emergency pass to succeed, %v did", pass)
+	}
+	if effective.RightSkip[2] != params.RightSkip[2]+params.EmergencyStretch {
+		t.Errorf("expected effective right skip to include emergency stretch, is %v", effective.RightSkip)
+	}
+}
+
 // crude implementation just for testing
 func justify(text string, l int, even bool) string {
 	t := strings.Trim(text, " \t\n")
@@ -238,3 +438,43 @@ func justify(text string, l int, even bool) string {
 	}
 	return b.String()
 }
+
+type knotMark struct {
+	pos  int
+	knot khipu.Knot
+}
+
+func (m knotMark) Position() int    { return m.pos }
+func (m knotMark) Knot() khipu.Knot { return m.knot }
+
+func TestKPMicrotype(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	quote, end := khipu.NewTextBox("“Quote"), khipu.NewTextBox("end.")
+	quote.Width, end.Width = 60*dimen.BP, 40*dimen.BP
+	knots := []khipu.Knot{quote, khipu.NewGlue(10*dimen.BP, 0, 0), end, khipu.Penalty(0)}
+	segment := func(params *linebreak.Parameters) linebreak.WSS {
+		fb := &feasibleBreakpoint{mark: provisionalMark(-1), books: map[int]*bookkeeping{0: {}}}
+		for i, knot := range knots {
+			fb.UpdateSegmentBookkeeping(knotMark{pos: i, knot: knot}, params)
+		}
+		return fb.segmentWidth(0, false, params)
+	}
+	params := NewKPDefaultParameters()
+	if w := segment(params); w.W != 110*dimen.BP || w.Max != w.W {
+		t.Errorf("expected rigid segment of 110bp, is %v", w)
+	}
+	params.Protrusion = linebreak.DefaultProtrusion()
+	params.FontStretch, params.FontShrink = 25, 25
+	// “ protrudes by 5bp, . by 7bp; text boxes may stretch or shrink by 2.5bp in total
+	w := segment(params)
+	if w.W != 98*dimen.BP || w.Max != 98*dimen.BP+5*dimen.BP/2 || w.Min != 98*dimen.BP-5*dimen.BP/2 {
+		t.Errorf("expected segment of 98bp ±2.5bp, is %v", w)
+	}
+	whatsit := khipu.NewWhatsit("anchor", nil)
+	knots = []khipu.Knot{whatsit, quote, khipu.NewGlue(10*dimen.BP, 0, 0), end, whatsit, khipu.Penalty(0)}
+	if w = segment(params); w.W != 98*dimen.BP {
+		t.Errorf("expected whatsits not to prevent protrusion, segment is %v", w)
+	}
+}
@@ -0,0 +1,237 @@
+package knuthplass
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/npillmayer/gotype/engine/khipu"
+	"github.com/npillmayer/gotype/engine/khipu/linebreak"
+)
+
+// Solution is a sequence of breakpoints for a paragraph, together with its
+// total cost.
+type Solution struct {
+	Linecount   int            // number of lines of the broken paragraph
+	Breakpoints []khipu.Mark   // breakpoints, including the start of the paragraph
+	Demerits    int32          // total demerits of all lines
+	Fitness     []FitnessClass // fitness class for each line
+}
+
+func (s Solution) String() string {
+	return fmt.Sprintf("[%d lines, d=%s: %v]", s.Linecount, demeritsString(s.Demerits), s.Breakpoints)
+}
+
+// FindAlternatives finds up to n alternative solutions for breaking a paragraph,
+// for every linecount variant. Alternatives for a linecount are ranked by
+// increasing total demerits, i.e. the first solution for a linecount is the optimal
+// one.
+//
+// The first return value is a slice of linecount variants, in decreasing order of
+// linebreak quality (see FindBreakpoints). The second return value maps
+// each linecount to its ranked alternatives.
+//
+// Alternatives are taken from the graph of feasible breakpoints constructed
+// during line breaking, including segments which have been pruned in favour
+// of cheaper ones. Thus, no additional passes over the paragraph are necessary.
+func FindAlternatives(cursor linebreak.Cursor, parshape linebreak.ParShape,
+	params *linebreak.Parameters, n int) ([]int, map[int][]Solution, error) {
+	//
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("Number of alternatives must be positive, is %d", n)
+	}
+	kp, err := breakpointGraph(cursor, parshape, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	variants, _ := kp.collectFeasibleBreakpoints(kp.end)
+	if len(variants) == 0 {
+		return nil, nil, fmt.Errorf("No breakpoints could be found for paragraph")
+	}
+	ranked := kp.collectAlternatives(kp.end, variants, n)
+	return variants, ranked, nil
+}
+
+// BreakParagraphWithLooseness breaks a paragraph with a line count differing
+// from the optimal one, similar to TeX's \looseness.
+// A looseness of -1 will try to make the paragraph one line shorter, a
+// looseness of +1 one line longer. If the requested line count is not
+// feasible, the line count closest to it will be selected.
+// Only solutions with total demerits not exceeding limit are considered. If no
+// such solution exists, the optimal solution is returned.
+//
+// Clients may compare the returned solution's linecount to the line count they
+// asked for, to find out if the paragraph could be tightened or loosened.
+func BreakParagraphWithLooseness(cursor linebreak.Cursor, parshape linebreak.ParShape,
+	params *linebreak.Parameters, looseness int, limit int32) (Solution, error) {
+	//
+	variants, ranked, err := FindAlternatives(cursor, parshape, params, 1)
+	if err != nil {
+		return Solution{}, err
+	}
+	best := ranked[variants[0]][0]
+	if looseness == 0 {
+		return best, nil
+	}
+	target := best.Linecount + looseness
+	chosen := best
+	for _, linecnt := range variants { // variants are sorted by increasing demerits
+		if len(ranked[linecnt]) == 0 || ranked[linecnt][0].Demerits > limit {
+			continue
+		}
+		if looseness < 0 && (linecnt >= chosen.Linecount || linecnt < target) {
+			continue
+		}
+		if looseness > 0 && (linecnt <= chosen.Linecount || linecnt > target) {
+			continue
+		}
+		chosen = ranked[linecnt][0]
+	}
+	T().Infof("K&P looseness %d: selected %d lines instead of %d", looseness,
+		chosen.Linecount, best.Linecount)
+	return chosen, nil
+}
+
+// collectAlternatives collects the top n paths through the graph of feasible
+// breakpoints, for every linecount given.
+func (kp *linebreaker) collectAlternatives(last *feasibleBreakpoint, linecounts []int,
+	n int) map[int][]Solution {
+	//
+	paths := make(map[pathKey][]path)
+	solutions := make(map[int][]Solution, len(linecounts))
+	for _, linecnt := range linecounts {
+		for _, p := range kp.bestPathsTo(last, linecnt, n, paths) {
+			solutions[linecnt] = append(solutions[linecnt], Solution{
+				Linecount:   linecnt,
+				Breakpoints: p.marks(),
+				Demerits:    p.total,
+				Fitness:     p.fitness(),
+			})
+		}
+	}
+	return solutions
+}
+
+// path is a (partial) sequence of breakpoints from the start of a paragraph
+// up to a breakpoint. Paths are linked backwards.
+type path struct {
+	total int32               // sum of costs along the path
+	fb    *feasibleBreakpoint // end point of this path
+	edge  wEdge               // the line ending at fb
+	pred  *path               // predecessor path or nil at start of paragraph
+}
+
+type pathKey struct {
+	position int          // position of breakpoint
+	linecnt  int          // line count up to breakpoint
+	fitness  FitnessClass // fitness class of the line ending at the breakpoint
+}
+
+// marks returns the breakpoints of a path in order of increasing position.
+func (p *path) marks() []khipu.Mark {
+	var marks []khipu.Mark
+	for q := p; q != nil; q = q.pred {
+		marks = append(marks, q.fb.mark)
+	}
+	for i := len(marks)/2 - 1; i >= 0; i-- { // exchange b[i] with opposite
+		opp := len(marks) - 1 - i
+		marks[i], marks[opp] = marks[opp], marks[i]
+	}
+	return marks
+}
+
+// fitness returns the fitness classes of the lines of a path.
+func (p *path) fitness() []FitnessClass {
+	var fitness []FitnessClass
+	for q := p; q != nil && q.pred != nil; q = q.pred {
+		fitness = append(fitness, q.edge.fitness)
+	}
+	for i := len(fitness)/2 - 1; i >= 0; i-- {
+		opp := len(fitness) - 1 - i
+		fitness[i], fitness[opp] = fitness[opp], fitness[i]
+	}
+	return fitness
+}
+
+// isFeasible returns true if every line of a path has a badness below a given
+// tolerance.
+func (p *path) isFeasible(tolerance int32) bool {
+	for q := p; q != nil && q.pred != nil; q = q.pred {
+		if q.edge.badness >= tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// bestPathsTo returns up to n cheapest paths from the start of the paragraph to fb,
+// producing linecnt lines. Intermediate results are memoized in paths.
+func (kp *linebreaker) bestPathsTo(fb *feasibleBreakpoint, linecnt int, n int,
+	paths map[pathKey][]path) []path {
+	//
+	var best []path
+	for f := 0; f < fitnessClasses; f++ {
+		best = append(best, kp.bestPaths(fb, linecnt, FitnessClass(f), n, paths)...)
+	}
+	return cheapestPaths(best, n)
+}
+
+// bestPaths returns up to n cheapest paths from the start of the paragraph to fb,
+// producing linecnt lines, with the last line of a given fitness class.
+// Intermediate results are memoized in paths.
+//
+// This is a straightforward k-shortest-paths search on a DAG: the best paths to a
+// breakpoint consist of the best paths to any of its predecessors, extended by the
+// edge from the predecessor. Demerits for visually incompatible lines depend on
+// the fitness class of the predecessor's line and are re-calculated for every
+// combination.
+func (kp *linebreaker) bestPaths(fb *feasibleBreakpoint, linecnt int, fitness FitnessClass,
+	n int, paths map[pathKey][]path) []path {
+	//
+	if fb == kp.root {
+		if linecnt == 0 && fitness == DecentFit {
+			return []path{{fb: fb}}
+		}
+		return nil
+	}
+	if linecnt <= 0 {
+		return nil
+	}
+	key := pathKey{position: fb.mark.Position(), linecnt: linecnt, fitness: fitness}
+	if p, ok := paths[key]; ok {
+		return p
+	}
+	var candidates []path
+	for _, edge := range kp.EdgesWithLabel(fb, linecnt, true) {
+		from := kp.StartOfEdge(edge)
+		if from == nil || edge.fitness != fitness {
+			continue
+		}
+		cost := edge.cost - fitnessDemerits(edge.prevFitness, fitness, kp.params)
+		for prev := 0; prev < fitnessClasses; prev++ {
+			preds := kp.bestPaths(from, linecnt-1, FitnessClass(prev), n, paths)
+			adj := fitnessDemerits(FitnessClass(prev), fitness, kp.params)
+			for i := range preds {
+				candidates = append(candidates, path{
+					total: preds[i].total + cost + adj,
+					fb:    fb,
+					edge:  edge,
+					pred:  &preds[i],
+				})
+			}
+		}
+	}
+	candidates = cheapestPaths(candidates, n)
+	paths[key] = candidates
+	return candidates
+}
+
+// cheapestPaths sorts paths by increasing total cost and returns up to n of them.
+func cheapestPaths(candidates []path, n int) []path {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].total < candidates[j].total
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
@@ -0,0 +1,135 @@
+package knuthplass
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/npillmayer/gotype/core/parameters"
+	"github.com/npillmayer/gotype/engine/khipu"
+	"github.com/npillmayer/gotype/engine/khipu/linebreak"
+)
+
+// Pass identifies one of the line-breaking passes of BreakParagraphInPasses.
+type Pass int8
+
+// Line-breaking passes, modelled after TeX.
+const (
+	NoPass        Pass = iota // no pass has been run
+	FirstPass                 // without hyphenation, at PreTolerance
+	SecondPass                // with hyphenation, at Tolerance
+	EmergencyPass             // with hyphenation and EmergencyStretch, at Tolerance
+)
+
+func (pass Pass) String() string {
+	switch pass {
+	case FirstPass:
+		return "first pass"
+	case SecondPass:
+		return "second pass"
+	case EmergencyPass:
+		return "emergency pass"
+	}
+	return "no pass"
+}
+
+// parameters derives the line-breaking parameters for a pass from a set of
+// client parameters.
+func (pass Pass) parameters(p *linebreak.Parameters) *linebreak.Parameters {
+	passParams := *p
+	switch pass {
+	case FirstPass: // discretionaries are never feasible breakpoints
+		passParams.Tolerance = p.PreTolerance
+		passParams.HyphenPenalty = linebreak.InfinityDemerits
+		passParams.ExHyphenPenalty = linebreak.InfinityDemerits
+	case EmergencyPass: // emergency stretch is added to every line
+		rs := p.RightSkip
+		passParams.RightSkip = khipu.NewGlue(rs[0], rs[1], rs[2]+p.EmergencyStretch)
+	}
+	return &passParams
+}
+
+// BreakParagraphInPasses breaks a paragraph using a sequence of passes, similar
+// to TeX:
+//
+// (1) A first pass tries to find a solution without hyphenating words, with every
+// line's badness below params.PreTolerance. Discretionaries already present in
+// the khipu are not considered as breakpoints. If PreTolerance is negative, the
+// first pass is skipped.
+//
+// (2) If the first pass fails, words are hyphenated (using khipu.HyphenateTextBoxes) and
+// a second pass tries to find a solution with every line's badness below
+// params.Tolerance.
+//
+// (3) If the second pass fails as well and params.EmergencyStretch is positive,
+// an emergency pass adds EmergencyStretch to the stretchability of every line.
+//
+// The khipu should not be hyphenated by the client. Hyphenation is governed by
+// regs; if regs is nil, the second pass will not hyphenate. pipeline may be nil.
+// As a cursor can iterate over a khipu just once, clients have to provide a
+// function to create a new cursor for each pass.
+//
+// The solution of the last pass run is returned, together with an indicator
+// of this pass and the parameters effective for it. If the last pass fails, the
+// solution will contain lines with a badness above Tolerance (underfull or
+// overfull lines). Clients should pack and diagnose the lines with the returned
+// parameters (see linebreak.Parameters.Skips), as the emergency pass widens
+// the right skip of every line.
+func BreakParagraphInPasses(kh *khipu.Khipu, cursor func(*khipu.Khipu) linebreak.Cursor,
+	parshape linebreak.ParShape, params *linebreak.Parameters,
+	pipeline *khipu.TypesettingPipeline, regs *parameters.TypesettingRegisters) (
+	Solution, Pass, *linebreak.Parameters, error) {
+	//
+	if params == nil {
+		params = NewKPDefaultParameters()
+	}
+	if kh == nil || cursor == nil {
+		return Solution{}, NoPass, params, fmt.Errorf("Cannot break a paragraph without khipu or cursor")
+	}
+	var solution Solution
+	pass, passParams := NoPass, params
+	for _, p := range []Pass{FirstPass, SecondPass, EmergencyPass} {
+		switch p {
+		case FirstPass:
+			if params.PreTolerance < 0 {
+				continue
+			}
+		case SecondPass:
+			if regs != nil {
+				if pipeline == nil {
+					pipeline = khipu.PrepareTypesettingPipeline(strings.NewReader(""), nil)
+				}
+				khipu.HyphenateTextBoxes(kh, pipeline, regs)
+			}
+		case EmergencyPass:
+			if params.EmergencyStretch <= 0 {
+				continue
+			}
+		}
+		pass = p
+		passParams = p.parameters(params)
+		kp, err := breakpointGraph(cursor(kh), parshape, passParams)
+		if err != nil {
+			return Solution{}, pass, passParams, err
+		}
+		variants, _ := kp.collectFeasibleBreakpoints(kp.end)
+		if len(variants) == 0 {
+			return Solution{}, pass, passParams, fmt.Errorf("No breakpoints could be found for paragraph")
+		}
+		best := kp.bestPathsTo(kp.end, variants[0], 1, make(map[pathKey][]path))
+		if len(best) == 0 {
+			return Solution{}, pass, passParams, fmt.Errorf("No breakpoints could be found for paragraph")
+		}
+		solution = Solution{
+			Linecount:   variants[0],
+			Breakpoints: best[0].marks(),
+			Demerits:    best[0].total,
+			Fitness:     best[0].fitness(),
+		}
+		if best[0].isFeasible(passParams.Tolerance) {
+			T().Infof("K&P %v succeeded with %d lines", pass, solution.Linecount)
+			return solution, pass, passParams, nil
+		}
+		T().Infof("K&P %v failed to find a feasible solution", pass)
+	}
+	return solution, pass, passParams, nil
+}
@@ -60,10 +60,16 @@ type Parameters struct {
 	ExHyphenPenalty      int32       // penalty for explicit words
 	DoubleHyphenDemerits int32       // demerits for consecutive hyphens
 	FinalHyphenDemerits  int32       // demerits for hyphen in the last line
+	AdjDemerits          int32       // demerits for visually incompatible adjacent lines
+	HBadness             int32       // badness above which underfull lines are reported
+	HFuzz                dimen.Dimen // overshoot above which overfull lines are reported
 	EmergencyStretch     dimen.Dimen // stretching acceptable when desperate
 	LeftSkip             khipu.Glue  // glue at left edge of paragraphs
 	RightSkip            khipu.Glue  // glue at right edge of paragraphs
 	ParFillSkip          khipu.Glue  // glue at the end of a paragraph
+	Protrusion           *Protrusion // character protrusion into the margins, or nil
+	FontStretch          int32       // font expansion: stretch of glyph widths, in thousandths
+	FontShrink           int32       // font expansion: shrink of glyph widths, in thousandths
 }
 
 // DefaultParameters are the standard line-breaking parameters.
@@ -77,6 +83,9 @@ var DefaultParameters = &Parameters{
 	ExHyphenPenalty:      50,
 	DoubleHyphenDemerits: 0,
 	FinalHyphenDemerits:  50,
+	AdjDemerits:          0,
+	HBadness:             1000,
+	HFuzz:                dimen.Dimen(dimen.PT / 10),
 	EmergencyStretch:     dimen.Dimen(dimen.BP * 50),
 	LeftSkip:             khipu.NewGlue(0, 0, 0),
 	RightSkip:            khipu.NewGlue(0, 0, 0),
@@ -108,6 +117,16 @@ func (wss WSS) SetFromKnot(knot khipu.Knot) WSS {
 	return wss
 }
 
+// SetFromKnots sets the dimensions of wss to the sum of the dimensions of
+// a list of knots, e.g., the pre-break knots of a discretionary.
+func (wss WSS) SetFromKnots(knots []khipu.Knot) WSS {
+	wss = WSS{}
+	for _, knot := range knots {
+		wss = wss.Add(WSS{}.SetFromKnot(knot))
+	}
+	return wss
+}
+
 // Add adds dimensions from a given WSS to wss, returning a new WSS.
 func (wss WSS) Add(other WSS) WSS {
 	return WSS{
@@ -163,8 +182,10 @@ type Cursor interface {
 }
 
 // ParShape is a type to return the line length for a given line number.
+// LineIndent returns the offset of a line from the left edge of the paragraph.
 type ParShape interface {
 	LineLength(int) dimen.Dimen
+	LineIndent(int) dimen.Dimen
 }
 
 type rectParShape dimen.Dimen
@@ -173,6 +194,10 @@ func (r rectParShape) LineLength(int) dimen.Dimen {
 	return dimen.Dimen(r)
 }
 
+func (r rectParShape) LineIndent(int) dimen.Dimen {
+	return 0
+}
+
 // RectangularParShape returns a Parshape for paragraphs of constant line length.
 func RectangularParShape(linelen dimen.Dimen) ParShape {
 	return rectParShape(linelen)
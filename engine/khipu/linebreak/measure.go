@@ -70,8 +70,18 @@ func (fwc FixedWidthCursor) setTextDimens(knot khipu.Knot) (khipu.Knot, bool) {
 	switch knot.Type() {
 	case khipu.KTDiscretionary:
 		d := knot.(khipu.Discretionary)
-		isChanged = (d.Width != fwc.glyphWidth)
-		d.Width = fwc.glyphWidth
+		if d.HyphenChar != 0 {
+			isChanged = (d.Width != fwc.glyphWidth)
+			d.Width = fwc.glyphWidth
+		}
+		for _, list := range [][]khipu.Knot{d.Pre, d.Post, d.NoBreak} {
+			for i, k := range list {
+				var changed bool
+				list[i], changed = fwc.setTextDimens(k)
+				isChanged = isChanged || changed
+			}
+		}
+		return d, isChanged
 	case khipu.KTTextBox:
 		b := knot.(*khipu.TextBox)
 		newW := dimen.Dimen(len(b.Text())) * fwc.glyphWidth
@@ -80,17 +90,10 @@ func (fwc FixedWidthCursor) setTextDimens(knot khipu.Knot) (khipu.Knot, bool) {
 		b.Height = fwc.glyphWidth
 	case khipu.KTGlue:
 		g := knot.(khipu.Glue)
-		g[0] = max(1, fwc.glyphWidth)
+		g[0] = dimen.Max(1, fwc.glyphWidth)
 		g[1] = 0
-		g[2] = max(1, fwc.glyphWidth*dimen.Dimen(fwc.stretch))
+		g[2] = dimen.Max(1, fwc.glyphWidth*dimen.Dimen(fwc.stretch))
 		return g, true
 	}
 	return knot, isChanged
 }
-
-func max(d1, d2 dimen.Dimen) dimen.Dimen {
-	if d1 > d2 {
-		return d1
-	}
-	return d2
-}
@@ -0,0 +1,108 @@
+package linebreak
+
+import (
+	"unicode/utf8"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+)
+
+// Microtypography, similar to pdfTeX:
+//
+// Character protrusion lets certain characters at the edges of a line, e.g.
+// hyphens, periods and quotes, hang into the margin. This makes the margins appear
+// straighter and leaves more room for the text of a line.
+//
+// Font expansion lets every line scale the widths of its glyphs within limits
+// (Parameters.FontStretch and Parameters.FontShrink), in addition to the
+// flexibility of the glue in the line.
+
+// Protrusion holds factors for characters protruding into the margins, in
+// thousandths of the width of a character, similar to pdfTeX's \lpcode and
+// \rpcode.
+type Protrusion struct {
+	Left  map[rune]int32 // factors for characters at the start of a line
+	Right map[rune]int32 // factors for characters at the end of a line
+}
+
+// DefaultProtrusion returns protrusion factors for punctuation, modelled after
+// the defaults of LaTeX's microtype package.
+func DefaultProtrusion() *Protrusion {
+	return &Protrusion{
+		Left: map[rune]int32{
+			'‘': 300, '“': 500, '„': 400, '«': 400, '‹': 400,
+			'\'': 300, '"': 500, '(': 50, '[': 50,
+		},
+		Right: map[rune]int32{
+			'.': 700, ',': 700, ':': 500, ';': 500, '!': 100, '?': 100,
+			'-': 700, '\u2010': 700, '\u00AD': 700, '–': 300, '—': 200,
+			'’': 700, '”': 500, '»': 400, '›': 400, '\'': 700, '"': 500, ')': 50, ']': 50,
+		},
+	}
+}
+
+// LeftProtrusion returns the amount a knot at the start of a line may protrude
+// into the left margin.
+func (p *Protrusion) LeftProtrusion(knot khipu.Knot) dimen.Dimen {
+	if p == nil {
+		return 0
+	}
+	if box, ok := knot.(*khipu.TextBox); ok {
+		r, _ := utf8.DecodeRuneInString(box.Text())
+		return protrude(p.Left[r], glyphWidth(box, true))
+	}
+	return 0
+}
+
+// RightProtrusion returns the amount a knot at the end of a line may protrude
+// into the right margin. For a discretionary, the end of its pre-break knots
+// (usually a hyphen) will protrude.
+func (p *Protrusion) RightProtrusion(knot khipu.Knot) dimen.Dimen {
+	if p == nil {
+		return 0
+	}
+	switch k := knot.(type) {
+	case *khipu.TextBox:
+		r, _ := utf8.DecodeLastRuneInString(k.Text())
+		return protrude(p.Right[r], glyphWidth(k, false))
+	case khipu.Discretionary:
+		if pre := k.PreBreak(); len(pre) > 0 {
+			return p.RightProtrusion(pre[len(pre)-1])
+		}
+	}
+	return 0
+}
+
+func protrude(factor int32, w dimen.Dimen) dimen.Dimen {
+	return w.MulRatio(int64(factor), 1000)
+}
+
+// glyphWidth returns the width of the first or last glyph of a text box.
+// For text boxes without glyph information, the width is estimated from the
+// number of characters.
+func glyphWidth(box *khipu.TextBox, first bool) dimen.Dimen {
+	if glyphs := box.Glyphs(); glyphs != nil && glyphs.GlyphCount() > 0 {
+		i := 0
+		if !first {
+			i = glyphs.GlyphCount() - 1
+		}
+		return dimen.Dimen(glyphs.GetGlyphInfoAt(i).XAdvance() * float64(dimen.BP))
+	}
+	if n := utf8.RuneCountInString(box.Text()); n > 0 {
+		return box.W() / dimen.Dimen(n)
+	}
+	return 0
+}
+
+// KnotWSS returns the elastic width of a knot, with font expansion applied to
+// text boxes: a text box may stretch by params.FontStretch and shrink by
+// params.FontShrink thousandths of its width.
+func (params *Parameters) KnotWSS(knot khipu.Knot) WSS {
+	wss := WSS{}.SetFromKnot(knot)
+	if knot == nil || knot.Type() != khipu.KTTextBox {
+		return wss
+	}
+	wss.Max += wss.W.MulRatio(int64(params.FontStretch), 1000)
+	wss.Min -= wss.W.MulRatio(int64(params.FontShrink), 1000)
+	return wss
+}
@@ -0,0 +1,241 @@
+package linebreak
+
+import (
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+// Line numbers for ParShapes start at 1.
+
+// --- \parshape -------------------------------------------------------------
+
+// LineSpec specifies the indentation and the length of a single line.
+type LineSpec struct {
+	Indent dimen.Dimen // indentation from the left edge of the paragraph
+	Length dimen.Dimen // length of the line
+}
+
+type listParShape []LineSpec
+
+func (l listParShape) LineLength(lineno int) dimen.Dimen {
+	return l.spec(lineno).Length
+}
+
+func (l listParShape) LineIndent(lineno int) dimen.Dimen {
+	return l.spec(lineno).Indent
+}
+
+func (l listParShape) spec(lineno int) LineSpec {
+	if len(l) == 0 {
+		return LineSpec{}
+	}
+	if lineno < 1 {
+		lineno = 1
+	} else if lineno > len(l) {
+		lineno = len(l)
+	}
+	return l[lineno-1]
+}
+
+// ListParShape returns a ParShape for a list of line specifications, similar to
+// TeX's \parshape: line n of a paragraph is shaped by specification n, lines
+// beyond the list are shaped by the last specification.
+func ListParShape(lines ...LineSpec) ParShape {
+	return listParShape(lines)
+}
+
+// --- \hangindent and \hangafter --------------------------------------------
+
+type hangingParShape struct {
+	linelen    dimen.Dimen
+	hangindent dimen.Dimen
+	hangafter  int
+}
+
+func (h hangingParShape) LineLength(lineno int) dimen.Dimen {
+	return h.spec(lineno).Length
+}
+
+func (h hangingParShape) LineIndent(lineno int) dimen.Dimen {
+	return h.spec(lineno).Indent
+}
+
+func (h hangingParShape) spec(lineno int) LineSpec {
+	hanging := (h.hangafter >= 0 && lineno > h.hangafter) ||
+		(h.hangafter < 0 && lineno <= -h.hangafter)
+	if !hanging || h.hangindent == 0 {
+		return LineSpec{Length: h.linelen}
+	}
+	if h.hangindent < 0 { // indentation at the right edge
+		return LineSpec{Length: h.linelen + h.hangindent}
+	}
+	return LineSpec{Indent: h.hangindent, Length: h.linelen - h.hangindent}
+}
+
+// HangingParShape returns a ParShape for hanging indentation, similar to TeX's
+// \hangindent and \hangafter: if hangafter >= 0, lines after line number hangafter
+// are indented, otherwise the first |hangafter| lines are indented.
+// A positive hangindent indents lines at the left edge, a negative one at the
+// right edge.
+func HangingParShape(linelen dimen.Dimen, hangindent dimen.Dimen, hangafter int) ParShape {
+	return hangingParShape{linelen: linelen, hangindent: hangindent, hangafter: hangafter}
+}
+
+// IndentedParShape returns a ParShape for paragraphs with an indented first line.
+func IndentedParShape(linelen dimen.Dimen, parindent dimen.Dimen) ParShape {
+	return hangingParShape{linelen: linelen, hangindent: parindent, hangafter: -1}
+}
+
+// --- Text flowing around shapes --------------------------------------------
+
+// LineBands is a type to return the vertical band [top…bottom) a line of a
+// paragraph occupies, for a given line number starting at 1. Y-coordinates
+// grow downwards.
+//
+// Line breakers do not know the heights of lines in advance, thus bands are
+// usually estimated by the client, e.g. from the line heights of a previous
+// run of the line breaker.
+type LineBands func(lineno int) (top, bottom dimen.Dimen)
+
+// FixedPitch returns line bands for lines of a fixed pitch: the paragraph
+// starts at vertical position top and line n occupies the band from
+// top + (n-1)*baselineskip to top + n*baselineskip.
+func FixedPitch(top, baselineskip dimen.Dimen) LineBands {
+	return func(lineno int) (dimen.Dimen, dimen.Dimen) {
+		if lineno < 1 {
+			lineno = 1
+		}
+		t := top + dimen.Dimen(lineno-1)*baselineskip
+		return t, t + baselineskip
+	}
+}
+
+// A flowParShape determines line lengths from the vertical position of lines.
+type flowParShape struct {
+	bands      LineBands   // vertical positions of lines
+	linelen    dimen.Dimen // width of the text area, for exclusions
+	exclusions []dimen.Rect
+	polygon    []dimen.Point
+}
+
+func (f flowParShape) LineLength(lineno int) dimen.Dimen {
+	return f.spec(lineno).Length
+}
+
+func (f flowParShape) LineIndent(lineno int) dimen.Dimen {
+	return f.spec(lineno).Indent
+}
+
+func (f flowParShape) spec(lineno int) LineSpec {
+	top, bottom := f.bands(lineno)
+	if f.polygon != nil {
+		return polygonSpan(f.polygon, top, bottom)
+	}
+	return exclusionSpan(f.linelen, f.exclusions, top, bottom)
+}
+
+// ExclusionParShape returns a ParShape for text flowing around rectangular
+// areas, e.g. figures. Exclusion rectangles are given in the coordinate system
+// of the text area, which is linelen wide, with y-coordinates growing downwards.
+// The vertical positions of lines are given by bands, e.g. FixedPitch for
+// lines which are a fixed distance apart. Each line is set into the widest
+// horizontal span not covered by exclusions.
+func ExclusionParShape(linelen dimen.Dimen, bands LineBands, exclusions ...dimen.Rect) ParShape {
+	return flowParShape{bands: bands, linelen: linelen, exclusions: exclusions}
+}
+
+// PolygonParShape returns a ParShape for text filling a polygon. The polygon
+// is given as a list of vertices, with y-coordinates growing downwards. The
+// vertical positions of lines are given by bands (see ExclusionParShape).
+// Each line is set into the horizontal span covered by the polygon throughout
+// the line's vertical band. The polygon is expected to be horizontally convex,
+// i.e., every horizontal line crosses the polygon in a single span.
+func PolygonParShape(polygon []dimen.Point, bands LineBands) ParShape {
+	return flowParShape{bands: bands, polygon: polygon}
+}
+
+// exclusionSpan finds the widest span within [0…linelen] which is not covered
+// by an exclusion overlapping the vertical band [top…bottom).
+func exclusionSpan(linelen dimen.Dimen, exclusions []dimen.Rect, top, bottom dimen.Dimen) LineSpec {
+	spans := []LineSpec{{Indent: 0, Length: linelen}}
+	for _, r := range exclusions {
+		if r.BotR.Y <= top || r.TopL.Y >= bottom {
+			continue // exclusion does not overlap band
+		}
+		var remaining []LineSpec
+		for _, s := range spans {
+			if left := r.TopL.X - s.Indent; left > 0 { // part of span left of exclusion
+				remaining = append(remaining, LineSpec{Indent: s.Indent, Length: dimen.Min(left, s.Length)})
+			}
+			if right := s.Indent + s.Length - r.BotR.X; right > 0 { // part right of it
+				remaining = append(remaining, LineSpec{
+					Indent: s.Indent + s.Length - dimen.Min(right, s.Length),
+					Length: dimen.Min(right, s.Length),
+				})
+			}
+		}
+		spans = remaining
+	}
+	widest := LineSpec{}
+	for _, s := range spans {
+		if s.Length > widest.Length {
+			widest = s
+		}
+	}
+	return widest
+}
+
+// polygonSpan finds the horizontal span covered by a polygon throughout the
+// vertical band [top…bottom]. The polygon is sampled at the top and the bottom
+// of the band and at every vertex within the band.
+func polygonSpan(polygon []dimen.Point, top, bottom dimen.Dimen) LineSpec {
+	samples := []dimen.Dimen{top, bottom}
+	for _, p := range polygon {
+		if p.Y > top && p.Y < bottom {
+			samples = append(samples, p.Y)
+		}
+	}
+	var left, right dimen.Dimen
+	for i, y := range samples {
+		l, r, ok := polygonCrossing(polygon, y)
+		if !ok {
+			return LineSpec{}
+		}
+		if i == 0 || l > left {
+			left = l
+		}
+		if i == 0 || r < right {
+			right = r
+		}
+	}
+	if right <= left {
+		return LineSpec{Indent: left}
+	}
+	return LineSpec{Indent: left, Length: right - left}
+}
+
+// polygonCrossing returns the leftmost and rightmost x-coordinate where a
+// horizontal line at y crosses the edges of a polygon.
+func polygonCrossing(polygon []dimen.Point, y dimen.Dimen) (dimen.Dimen, dimen.Dimen, bool) {
+	var left, right dimen.Dimen
+	found := false
+	for i := range polygon {
+		p, q := polygon[i], polygon[(i+1)%len(polygon)]
+		if (y < p.Y && y < q.Y) || (y > p.Y && y > q.Y) {
+			continue // edge does not cross y
+		}
+		xs := []dimen.Dimen{p.X, q.X} // horizontal edge
+		if p.Y != q.Y {
+			xs = []dimen.Dimen{p.X + dimen.Dimen(int64(q.X-p.X)*int64(y-p.Y)/int64(q.Y-p.Y))}
+		}
+		for _, x := range xs {
+			if !found || x < left {
+				left = x
+			}
+			if !found || x > right {
+				right = x
+			}
+			found = true
+		}
+	}
+	return left, right, found
+}
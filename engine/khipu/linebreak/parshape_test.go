@@ -0,0 +1,90 @@
+package linebreak
+
+import (
+	"testing"
+
+	"github.com/npillmayer/gotype/core/dimen"
+)
+
+func TestHangingParShape(t *testing.T) {
+	hang := HangingParShape(100*dimen.BP, 20*dimen.BP, 2)
+	if hang.LineLength(2) != 100*dimen.BP || hang.LineLength(3) != 80*dimen.BP {
+		t.Errorf("expected lines after line 2 to be indented, are %v", []dimen.Dimen{
+			hang.LineLength(2), hang.LineLength(3)})
+	}
+	indent := IndentedParShape(100*dimen.BP, 15*dimen.BP)
+	if indent.LineLength(1) != 85*dimen.BP || indent.LineLength(2) != 100*dimen.BP {
+		t.Errorf("expected first line only to be indented")
+	}
+	list := ListParShape(LineSpec{Length: 50 * dimen.BP}, LineSpec{Indent: 10 * dimen.BP, Length: 70 * dimen.BP})
+	if list.LineLength(1) != 50*dimen.BP || list.LineLength(5) != 70*dimen.BP {
+		t.Errorf("expected last line spec to repeat, is %v", list.LineLength(5))
+	}
+	if list.LineIndent(1) != 0 || list.LineIndent(5) != 10*dimen.BP {
+		t.Errorf("expected last line to be indented by 10bp, is %v", list.LineIndent(5))
+	}
+}
+
+func TestFlowParShape(t *testing.T) {
+	// figure at the top right, 40bp wide, 25bp high
+	figure := dimen.Rect{TopL: dimen.Point{X: 60 * dimen.BP, Y: 0}, BotR: dimen.Point{X: 100 * dimen.BP, Y: 25 * dimen.BP}}
+	shape := ExclusionParShape(100*dimen.BP, FixedPitch(0, 10*dimen.BP), figure)
+	for lineno, expected := range map[int]dimen.Dimen{1: 60 * dimen.BP, 3: 60 * dimen.BP, 4: 100 * dimen.BP} {
+		if l := shape.LineLength(lineno); l != expected {
+			t.Errorf("expected line %d to be %.0fbp, is %.0fbp", lineno, expected.Points(), l.Points())
+		}
+	}
+	// a first line of 20bp height and lines of 5bp below it
+	bands := func(lineno int) (dimen.Dimen, dimen.Dimen) {
+		if lineno <= 1 {
+			return 0, 20 * dimen.BP
+		}
+		top := 20*dimen.BP + dimen.Dimen(lineno-2)*5*dimen.BP
+		return top, top + 5*dimen.BP
+	}
+	shape = ExclusionParShape(100*dimen.BP, bands, figure)
+	if l := shape.LineLength(2); l != 60*dimen.BP {
+		t.Errorf("expected line 2 to be beside the figure, is %.0fbp", l.Points())
+	}
+	if l := shape.LineLength(3); l != 100*dimen.BP {
+		t.Errorf("expected line 3 to be below the figure, is %.0fbp", l.Points())
+	}
+	// triangle with its tip at the top
+	triangle := []dimen.Point{{X: 50 * dimen.BP, Y: 0}, {X: 100 * dimen.BP, Y: 100 * dimen.BP}, {X: 0, Y: 100 * dimen.BP}}
+	shape = PolygonParShape(triangle, FixedPitch(0, 10*dimen.BP))
+	if l := shape.LineLength(1); l != 0 {
+		t.Errorf("expected first line in triangle to have length 0, is %.2fbp", l.Points())
+	}
+	if l := shape.LineLength(10); l != 90*dimen.BP {
+		t.Errorf("expected last line in triangle to have length 90bp, is %.2fbp", l.Points())
+	}
+}
+
+func TestAlignedParameters(t *testing.T) {
+	ragged := AlignedParameters(nil, RaggedRight, 20*dimen.BP)
+	if ragged.RightSkip.MaxW() != 20*dimen.BP || ragged.LeftSkip.MaxW() != 0 {
+		t.Errorf("expected right edge only to stretch, skips are %v and %v", ragged.LeftSkip, ragged.RightSkip)
+	}
+	if DefaultParameters.RightSkip.MaxW() != 0 {
+		t.Errorf("expected default parameters to remain unchanged")
+	}
+	// a line 10bp short of 100bp without interword stretch is acceptable when ragged
+	line := WSS{W: 90 * dimen.BP, Min: 90 * dimen.BP, Max: 90 * dimen.BP}
+	if b := Badness(line, 100*dimen.BP); b != InfinityDemerits {
+		t.Errorf("expected rigid line to be infinitely bad, is %d", b)
+	}
+	line = line.Add(WSS{}.SetFromKnot(ragged.RightSkip))
+	if b := Badness(line, 100*dimen.BP); b != 12 {
+		t.Errorf("expected ragged line to have badness 12, is %d", b)
+	}
+	centered := AlignedParameters(nil, Centered, 10*dimen.BP)
+	skips := centered.Skips()
+	if skips.LeftSkip.MaxW() != 10*dimen.BP || skips.RightSkip.MaxW() != 10*dimen.BP ||
+		skips.ParFillSkip.MaxW() != 0 {
+		t.Errorf("expected centered lines to stretch at both edges, skips are %v", skips)
+	}
+	if AlignmentFromCSS("start", true) != RaggedLeft || AlignmentFromCSS("center", false) != Centered ||
+		AlignmentFromCSS("justify", false) != Justified {
+		t.Errorf("CSS text-align not correctly mapped to alignments")
+	}
+}
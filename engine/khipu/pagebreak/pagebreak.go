@@ -0,0 +1,259 @@
+/*
+Package pagebreak implements an optimal page-breaking algorithm for vertical
+lists.
+
+Pages are broken similar to TeX's page builder, i.e. at penalties and at glue
+following non-discardable material, with the cost of a page calculated from its
+badness and the penalty at the breakpoint. Different from TeX, which breaks
+pages greedily, the total cost of all pages is minimized, as proposed
+by Michael F. Plass in his thesis "Optimal Pagination Techniques for
+Automatic Typesetting Systems".
+
+Vertical lists are usually created by stacking lines with method AppendLines
+of package khipu.
+Widow and club penalties are inserted there.
+
+BSD License
+
+Copyright (c) 2017–20, Norbert Pillmayer
+
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions
+are met:
+
+1. Redistributions of source code must retain the above copyright
+notice, this list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright
+notice, this list of conditions and the following disclaimer in the
+documentation and/or other materials provided with the distribution.
+
+3. Neither the name of this software nor the names of its contributors
+may be used to endorse or promote products derived from this software
+without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+"AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+(INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE. */
+package pagebreak
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/npillmayer/gotype/core/config/gtrace"
+	"github.com/npillmayer/gotype/core/config/tracing"
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+	"github.com/npillmayer/gotype/engine/khipu/linebreak"
+)
+
+// T traces to the core tracer.
+func T() tracing.Trace {
+	return gtrace.CoreTracer
+}
+
+// Costs of pages, modelled after TeX.
+const (
+	ejectPenalty int32 = -10000  // a penalty forcing a page break
+	deplorable   int32 = 100000  // cost of a page which is too loose
+	awfulBad     int32 = 1 << 30 // cost of an overfull page
+)
+
+// Parameters is a collection of configuration parameters for page-breaking.
+type Parameters struct {
+	TopSkip   dimen.Dimen // distance from the top of a page to the first baseline
+	MaxDepth  dimen.Dimen // maximum depth of the last line of a page
+	FinalFill khipu.Glue  // glue at the end of the last page
+}
+
+// DefaultParameters are the standard page-breaking parameters.
+var DefaultParameters = &Parameters{
+	TopSkip:   10 * dimen.PT,
+	MaxDepth:  4 * dimen.PT,
+	FinalFill: khipu.NewFill(1),
+}
+
+// Page is a page of a broken vertical list.
+type Page struct {
+	From, To int           // range [From…To) of the page's material in the vertical list
+	Break    khipu.Mark    // breakpoint ending the page, nil for the last page
+	Height   dimen.Dimen   // target height of the page
+	Content  linebreak.WSS // natural, minimum and maximum height of the page's material
+	Badness  int32         // TeX-like badness of the page, 0 <= b <= 10000
+	Penalty  int32         // penalty at the breakpoint
+	Cost     int32         // cost of the page
+}
+
+func (p Page) String() string {
+	return fmt.Sprintf("[page %d…%d: b=%d, p=%d, c=%d]", p.From, p.To, p.Badness, p.Penalty, p.Cost)
+}
+
+// IsOverfull returns true if the material of a page cannot be shrunk to the
+// page's height.
+func (p Page) IsOverfull() bool {
+	return p.Content.Min > p.Height
+}
+
+// BreakPages breaks a vertical list into pages of a given height.
+// Breakpoints are chosen to minimize the total cost of all pages.
+// Page breaks are forced by penalties <= -10000 and prohibited
+// by penalties >= 10000.
+//
+// Discardable knots at the top of a page are not part of the page. If a page
+// starts with a line, glue is added to bring its baseline to params.TopSkip.
+// The depth of the last line of a page is not counted, as long as it does not
+// exceed params.MaxDepth. The last page is filled with params.FinalFill.
+//
+// If no page break can be found to keep a page from overflowing, an overfull
+// page will be created.
+func BreakPages(vlist *khipu.Khipu, height dimen.Dimen, params *Parameters) ([]Page, error) {
+	if vlist == nil {
+		return nil, fmt.Errorf("Cannot break pages without a vertical list")
+	}
+	if height <= 0 {
+		return nil, fmt.Errorf("Page height must be positive, is %s", height)
+	}
+	if params == nil {
+		params = DefaultParameters
+	}
+	if vlist.Length() == 0 {
+		return nil, nil
+	}
+	pb := newPageBreaker(vlist, height, params)
+	return pb.optimalPages(), nil
+}
+
+// --- Page breaker ----------------------------------------------------------
+
+type pageBreaker struct {
+	vlist  *khipu.Khipu
+	knots  []khipu.Knot
+	breaks []breakpoint // feasible breakpoints, including start and end of the list
+	height dimen.Dimen
+	params *Parameters
+}
+
+type breakpoint struct {
+	pos     int        // position in the vertical list
+	penalty int32      // penalty for breaking here
+	mark    khipu.Mark // mark for the breakpoint, nil for start and end of list
+}
+
+func newPageBreaker(vlist *khipu.Khipu, height dimen.Dimen, params *Parameters) *pageBreaker {
+	pb := &pageBreaker{vlist: vlist, height: height, params: params}
+	pb.breaks = append(pb.breaks, breakpoint{pos: -1})
+	cursor := khipu.NewCursor(vlist)
+	for cursor.Next() {
+		knot := cursor.Knot()
+		pb.knots = append(pb.knots, knot)
+		switch knot.Type() {
+		case khipu.KTPenalty:
+			if p := cursor.AsPenalty().Demerits(); p < linebreak.InfinityDemerits {
+				pb.breaks = append(pb.breaks, breakpoint{cursor.Position(), p, cursor.Mark()})
+			}
+		case khipu.KTGlue:
+			if n := len(pb.knots); n > 1 && !pb.knots[n-2].IsDiscardable() {
+				pb.breaks = append(pb.breaks, breakpoint{cursor.Position(), 0, cursor.Mark()})
+			}
+		}
+	}
+	pb.breaks = append(pb.breaks, breakpoint{pos: len(pb.knots), penalty: ejectPenalty})
+	return pb
+}
+
+// optimalPages finds the sequence of pages with minimal total cost, using
+// dynamic programming: the best way to fill pages up to breakpoint k
+// is the best way up to some breakpoint j < k, plus a page from j to k.
+func (pb *pageBreaker) optimalPages() []Page {
+	n := len(pb.breaks)
+	total := make([]int64, n)
+	pred := make([]int, n)
+	pages := make([]Page, n)
+	for k := 1; k < n; k++ {
+		total[k] = math.MaxInt64
+		for j := k - 1; j >= 0; j-- {
+			if j < k-1 && pb.breaks[j+1].penalty <= ejectPenalty {
+				break // a page must not extend across a forced break
+			}
+			page := pb.page(j, k)
+			if page.IsOverfull() && j < k-1 {
+				break // pages will get even longer
+			}
+			if t := total[j] + int64(page.Cost); t < total[k] {
+				total[k], pred[k], pages[k] = t, j, page
+			}
+			if page.IsOverfull() {
+				break
+			}
+		}
+	}
+	var result []Page
+	for k := n - 1; k > 0; k = pred[k] {
+		result = append(result, pages[k])
+	}
+	for i := len(result)/2 - 1; i >= 0; i-- { // exchange p[i] with opposite
+		opp := len(result) - 1 - i
+		result[i], result[opp] = result[opp], result[i]
+	}
+	T().Infof("Page breaker found %d pages with total cost %d", len(result), total[n-1])
+	return result
+}
+
+// page measures the material between breakpoints j and k and calculates the
+// cost of a page containing it.
+func (pb *pageBreaker) page(j, k int) Page {
+	page := Page{From: pb.breaks[j].pos + 1, To: pb.breaks[k].pos, Height: pb.height}
+	for page.From < page.To && pb.knots[page.From].IsDiscardable() {
+		page.From++
+	}
+	if k < len(pb.breaks)-1 {
+		page.Break = pb.breaks[k].mark
+		page.Penalty = pb.breaks[k].penalty
+	}
+	w, max, min := pb.vlist.Measure(page.From, page.To)
+	page.Content = linebreak.WSS{W: w, Min: min, Max: max}
+	if page.From < page.To { // adjust for topskip and depth of last line
+		var adjust dimen.Dimen
+		if line, ok := pb.knots[page.From].(*khipu.Line); ok && pb.params.TopSkip > line.Height {
+			adjust += pb.params.TopSkip - line.Height
+		}
+		last := page.To - 1
+		for last > page.From && pb.knots[last].IsDiscardable() {
+			last--
+		}
+		if line, ok := pb.knots[last].(*khipu.Line); ok {
+			adjust -= line.Depth
+			if line.Depth > pb.params.MaxDepth {
+				adjust += line.Depth - pb.params.MaxDepth
+			}
+		}
+		page.Content = page.Content.Add(linebreak.WSS{W: adjust, Min: adjust, Max: adjust})
+	}
+	if k == len(pb.breaks)-1 {
+		page.Content = page.Content.Add(linebreak.WSS{}.SetFromKnot(pb.params.FinalFill))
+	}
+	page.Badness = linebreak.Badness(page.Content, pb.height)
+	switch {
+	case page.IsOverfull():
+		page.Cost = awfulBad
+	case page.Badness >= linebreak.InfinityDemerits:
+		page.Cost = deplorable
+	case page.Penalty <= ejectPenalty:
+		page.Cost = page.Badness
+	default:
+		page.Cost = page.Badness + page.Penalty
+	}
+	T().Debugf("candidate %s", page)
+	return page
+}
@@ -0,0 +1,89 @@
+package pagebreak
+
+import (
+	"testing"
+
+	"github.com/npillmayer/gotype/core/config/gtrace"
+	"github.com/npillmayer/gotype/core/config/tracing"
+	"github.com/npillmayer/gotype/core/config/tracing/gotestingadapter"
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/engine/khipu"
+)
+
+// testVList creates a paragraph of n lines, each 8bp high and 2bp deep, with
+// baselines 12bp apart. Interline glue may stretch by 4bp.
+// Penalties are inserted at the positions given.
+func testVList(n int, penalties map[int]khipu.Penalty) *khipu.Khipu {
+	vlist := khipu.NewVList()
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			if p, ok := penalties[i-1]; ok {
+				vlist.AppendKnot(p)
+			}
+			vlist.AppendKnot(khipu.NewGlue(2*dimen.BP, 0, 4*dimen.BP))
+		}
+		vlist.AppendKnot(&khipu.Line{Height: 8 * dimen.BP, Depth: 2 * dimen.BP})
+	}
+	return vlist
+}
+
+func testParams() *Parameters {
+	return &Parameters{MaxDepth: 4 * dimen.BP, FinalFill: khipu.NewFill(1)}
+}
+
+func TestPagesWidow(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelDebug)
+	// 5 lines fit exactly on a page, but a break after line 5 leaves a widow
+	vlist := testVList(6, map[int]khipu.Penalty{1: 150, 5: 150})
+	pages, err := BreakPages(vlist, 56*dimen.BP, testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("pages = %v", pages)
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+	if lines := countLines(vlist, pages[0]); lines != 4 {
+		t.Errorf("expected first page to avoid widow line and hold 4 lines, has %d", lines)
+	}
+	if pages[0].Badness != 100 || pages[1].Badness != 0 {
+		t.Errorf("expected badness 100 for page 1 and 0 for page 2, are %d and %d",
+			pages[0].Badness, pages[1].Badness)
+	}
+}
+
+func TestPagesForced(t *testing.T) {
+	gtrace.CoreTracer = gotestingadapter.New()
+	teardown := gotestingadapter.RedirectTracing(t)
+	defer teardown()
+	gtrace.CoreTracer.SetTraceLevel(tracing.LevelInfo)
+	vlist := testVList(12, map[int]khipu.Penalty{2: -10000})
+	pages, err := BreakPages(vlist, 56*dimen.BP, testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("pages = %v", pages)
+	if len(pages) != 3 || countLines(vlist, pages[0]) != 2 {
+		t.Errorf("expected 3 pages, the first one with 2 lines, got %v", pages)
+	}
+	for _, page := range pages {
+		if page.IsOverfull() {
+			t.Errorf("page %v is overfull", page)
+		}
+	}
+}
+
+func countLines(vlist *khipu.Khipu, page Page) int {
+	n := 0
+	cursor := khipu.NewCursor(vlist)
+	for cursor.Next() {
+		if cursor.Position() >= page.From && cursor.Position() < page.To &&
+			cursor.Knot().Type() == khipu.KTLine {
+			n++
+		}
+	}
+	return n
+}
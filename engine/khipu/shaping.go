@@ -0,0 +1,89 @@
+package khipu
+
+import (
+	"github.com/npillmayer/gotype/core/dimen"
+	"github.com/npillmayer/gotype/core/font"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
+)
+
+// NewShapedTextBox creates a text box from a sequence of glyphs, created by a
+// text shaper. The width of the box is the sum of the glyph advances, height
+// and depth are the maximum extents of the glyphs, as given by the font.
+func NewShapedTextBox(s string, shaper textshaping.TextShaper, typecase *font.TypeCase) *TextBox {
+	box := NewTextBox(s)
+	box.typecase = typecase
+	box.glyphs = shaper.Shape(s, typecase)
+	for i := 0; i < box.glyphs.GlyphCount(); i++ {
+		glyph := box.glyphs.GetGlyphInfoAt(i)
+		box.Width += fromPoints(glyph.XAdvance())
+		h, d, err := typecase.GlyphBounds(glyph.Glyph())
+		if err != nil {
+			CT().Errorf("cannot get bounds for glyph %d: %v", glyph.Glyph(), err)
+			continue
+		}
+		if hh := fromPoints(h - glyph.YPosition()); hh > box.Height {
+			box.Height = hh
+		}
+		if dd := fromPoints(d + glyph.YPosition()); dd > box.Depth {
+			box.Depth = dd
+		}
+	}
+	return box
+}
+
+// Glyphs returns the shaped glyphs of a text box, if available.
+func (b TextBox) Glyphs() textshaping.GlyphSequence {
+	return b.glyphs
+}
+
+// TypeCase returns the font of the glyphs of a text box, if available.
+func (b TextBox) TypeCase() *font.TypeCase {
+	return b.typecase
+}
+
+// fromPoints converts a dimension in points, as used by text shapers, to a
+// Dimen.
+func fromPoints(x float64) dimen.Dimen {
+	return dimen.Dimen(x * float64(dimen.BP))
+}
+
+// --- Text shaping in a pipeline --------------------------------------------
+
+// SetTextShaper sets a text shaper and a font for a pipeline. Text boxes
+// created by the pipeline will then carry glyphs and dimensions from the
+// shaper. Without a text shaper, text boxes have no dimensions.
+func (pipeline *TypesettingPipeline) SetTextShaper(shaper textshaping.TextShaper, typecase *font.TypeCase) {
+	pipeline.shaper = shaper
+	pipeline.typecase = typecase
+	pipeline.space = Glue{} // interword space will change with the font
+}
+
+// newTextBox creates a text box, shaped if the pipeline has a text shaper.
+func (pipeline *TypesettingPipeline) newTextBox(s string) *TextBox {
+	if pipeline == nil || pipeline.shaper == nil || pipeline.typecase == nil {
+		return NewTextBox(s)
+	}
+	return NewShapedTextBox(s, pipeline.shaper, pipeline.typecase)
+}
+
+// newDiscretionary creates a discretionary, with the width of the hyphen
+// measured by the pipeline's text shaper, if any.
+func (pipeline *TypesettingPipeline) newDiscretionary() Knot {
+	d := NewKnot(KTDiscretionary).(Discretionary)
+	if pipeline != nil && pipeline.shaper != nil && pipeline.typecase != nil {
+		d.Width = pipeline.newTextBox(string(d.HyphenChar)).Width
+	}
+	return d
+}
+
+// newSpellingDiscretionary creates a discretionary with pre-break, post-break
+// and no-break text, see Hyphenation.
+func (pipeline *TypesettingPipeline) newSpellingDiscretionary(pre, post, nobreak string) Discretionary {
+	knots := func(s string) []Knot {
+		if s == "" {
+			return nil
+		}
+		return []Knot{pipeline.newTextBox(s)}
+	}
+	return Discretionary{Pre: knots(pre), Post: knots(post), NoBreak: knots(nobreak)}
+}
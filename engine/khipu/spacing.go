@@ -0,0 +1,126 @@
+package khipu
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	params "github.com/npillmayer/gotype/core/parameters"
+)
+
+// Interword spacing is derived from the width of the space character of a font,
+// with stretch, shrink and extra space (after sentences) modelled after the
+// font dimensions of TeX's Computer Modern fonts.
+const (
+	defaultSpace      = 5 * dimen.BP // interword space for text without a font
+	spaceStretchRatio = 2            // stretch = space / 2
+	spaceShrinkRatio  = 3            // shrink = space / 3
+	extraSpaceRatio   = 3            // extra space = space / 3
+	normalSpaceFactor = 1000         // space factor between words
+	extraSpaceFactor  = 2000         // space factor from which on extra space is added
+)
+
+// fontSpace returns the natural interword glue for the pipeline's font, which
+// is the width of the space character. Without a text shaper, a default space
+// is used.
+func (pipeline *TypesettingPipeline) fontSpace() Glue {
+	if pipeline.space[0] == 0 {
+		w := defaultSpace
+		if pipeline.shaper != nil && pipeline.typecase != nil {
+			if sw := pipeline.newTextBox(" ").Width; sw > 0 {
+				w = sw
+			}
+		}
+		pipeline.space = NewGlue(w, w/spaceShrinkRatio, w/spaceStretchRatio)
+	}
+	return pipeline.space
+}
+
+// interwordGlue creates glue between words, according to the current space factor,
+// similar to TeX: a space factor above 1000 increases stretch and decreases
+// shrink, a space factor of at least 2000 (usually at the end of a sentence) adds extra
+// space. With P_FRENCHSPACING set, the space factor is ignored.
+// P_WORDSPACING is added to the natural width of the glue, as with CSS word-spacing.
+func (pipeline *TypesettingPipeline) interwordGlue(regs *params.TypesettingRegisters) Glue {
+	space := pipeline.fontSpace()
+	sf := int64(pipeline.spacefactor)
+	if !regs.B(params.P_FRENCHSPACING) && sf != normalSpaceFactor && sf != 0 {
+		w := space[0]
+		if sf >= extraSpaceFactor {
+			w += space[0] / extraSpaceRatio
+		}
+		space = NewGlue(w, space[1].MulRatio(normalSpaceFactor, sf), space[2].MulRatio(sf, normalSpaceFactor))
+	}
+	space[0] += regs.D(params.P_WORDSPACING)
+	return space
+}
+
+// letterSpacing adds space after every character of the text boxes of a khipu,
+// as with CSS letter-spacing. This includes the text of discretionaries, with
+// the hyphen of a plain discretionary.
+func letterSpacing(khipu *Khipu, ls dimen.Dimen) {
+	if ls == 0 {
+		return
+	}
+	spaceLetters := func(knots []Knot) {
+		for _, knot := range knots {
+			if box, ok := knot.(*TextBox); ok {
+				box.Width += ls * dimen.Dimen(utf8.RuneCountInString(box.text))
+			}
+		}
+	}
+	spaceLetters(khipu.knots)
+	for i, knot := range khipu.knots {
+		if d, ok := knot.(Discretionary); ok {
+			spaceLetters(d.Pre)
+			spaceLetters(d.Post)
+			spaceLetters(d.NoBreak)
+			if len(d.Pre) == 0 && d.HyphenChar != 0 { // discretionaries are values
+				d.Width += ls
+				khipu.knots[i] = d
+			}
+		}
+	}
+}
+
+// spaceFactor updates a space factor for a text, similar to TeX's \spacefactor:
+// sentence-ending punctuation sets a high space factor, closing quotes and
+// parentheses leave it unchanged. An uppercase letter lowers the space factor
+// slightly, such that a following period is treated as an abbreviation.
+func spaceFactor(text string, sf int) int {
+	for _, r := range text {
+		code := sfCode(r)
+		if code == 0 {
+			continue
+		}
+		if sf < normalSpaceFactor && code > normalSpaceFactor {
+			sf = normalSpaceFactor
+		} else {
+			sf = code
+		}
+	}
+	return sf
+}
+
+// sfCode returns a space factor code for a rune, with 0 meaning 'no change'.
+func sfCode(r rune) int {
+	switch r {
+	case '.', '?', '!':
+		return 3000
+	case ':':
+		return 2000
+	case ';':
+		return 1500
+	case ',':
+		return 1250
+	case ')', ']', '\'', '"', '’', '”', '»', '«':
+		return 0
+	}
+	if unicode.IsSpace(r) {
+		return 0
+	}
+	if unicode.IsUpper(r) {
+		return 999
+	}
+	return normalSpaceFactor
+}
@@ -0,0 +1,184 @@
+package khipu
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+// Some languages change the spelling of a word at a hyphenation point, e.g.,
+//
+//     German (traditional orthography):  Zucker  → Zuk-ker
+//     Dutch:                             reële   → re-ele
+//     Hungarian:                         asszony → asz-szony
+//
+// Hyphenation patterns only find the hyphenation points. Language hooks of
+// type SpellingChange create non-standard discretionaries for them.
+
+// A Hyphenation describes a non-standard hyphenation point within a word:
+// the word is split into Left and Right, separated by a discretionary
+// {Pre}{Post}{NoBreak}. Pre usually ends with a hyphen.
+type Hyphenation struct {
+	Left, Pre, Post, NoBreak, Right string
+}
+
+// A SpellingChange is a language hook for hyphenation points which change the
+// spelling of a word. It receives the text left and right of a hyphenation
+// point, as found by the hyphenation patterns, and returns a non-standard
+// hyphenation, or false for a regular hyphenation point.
+type SpellingChange func(left, right string) (Hyphenation, bool)
+
+var spellingChanges = struct {
+	sync.RWMutex
+	hooks []spellingHook
+}{
+	hooks: []spellingHook{
+		{language.MustParse("de-1901"), GermanCK},
+		{language.Dutch, DutchDiaeresis},
+		{language.Hungarian, HungarianLongConsonants},
+	},
+}
+
+type spellingHook struct {
+	tag    language.Tag
+	change SpellingChange
+}
+
+// RegisterSpellingChange registers a spelling change hook for a language, given
+// as a BCP 47 language tag. The hook applies to the language and all its regional
+// variants. If the tag contains variants (e.g., "de-1901" for traditional German
+// orthography), the hook applies only to languages with these variants.
+// A hook registered later takes precedence.
+func RegisterSpellingChange(lang string, change SpellingChange) error {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return err
+	}
+	spellingChanges.Lock()
+	defer spellingChanges.Unlock()
+	spellingChanges.hooks = append(spellingChanges.hooks, spellingHook{tag, change})
+	return nil
+}
+
+// spellingChangeFor finds a spelling change hook for a language, or nil.
+func spellingChangeFor(lang string) SpellingChange {
+	tag, err := language.Parse(strings.Replace(lang, "_", "-", -1))
+	if err != nil {
+		if _, ok := err.(language.ValueError); !ok {
+			return nil
+		}
+	}
+	base, _ := tag.Base()
+	variants := tag.Variants()
+	spellingChanges.RLock()
+	defer spellingChanges.RUnlock()
+	for i := len(spellingChanges.hooks) - 1; i >= 0; i-- {
+		hook := spellingChanges.hooks[i]
+		if b, _ := hook.tag.Base(); b != base {
+			continue
+		}
+		if hasVariants(variants, hook.tag.Variants()) {
+			return hook.change
+		}
+	}
+	return nil
+}
+
+func hasVariants(variants, required []language.Variant) bool {
+	for _, r := range required {
+		found := false
+		for _, v := range variants {
+			found = found || v == r
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// GermanCK hyphenates "ck" as "k-k", as in traditional German orthography
+// (Zucker → Zuk-ker). Patterns for traditional orthography hyphenate
+// "ck" as "c-k".
+func GermanCK(left, right string) (Hyphenation, bool) {
+	if !strings.HasSuffix(left, "c") || !strings.HasPrefix(right, "k") {
+		return Hyphenation{}, false
+	}
+	return Hyphenation{
+		Left:    left[:len(left)-1],
+		Pre:     "k-",
+		NoBreak: "c",
+		Right:   right,
+	}, true
+}
+
+// diaereses maps vowels with diaeresis to their base vowels.
+var diaereses = map[rune]rune{'ë': 'e', 'ï': 'i', 'ö': 'o', 'ü': 'u', 'ä': 'a'}
+
+// DutchDiaeresis drops a diaeresis at the start of a syllable, which is
+// superfluous after a hyphen (reële → re-ele).
+func DutchDiaeresis(left, right string) (Hyphenation, bool) {
+	r, size := utf8.DecodeRuneInString(right)
+	base, ok := diaereses[r]
+	if !ok || left == "" {
+		return Hyphenation{}, false
+	}
+	return Hyphenation{
+		Left:    left,
+		Pre:     "-",
+		Post:    string(base),
+		NoBreak: string(r),
+		Right:   right[size:],
+	}, true
+}
+
+// hungarianDigraphs are Hungarian consonants written with two letters.
+// A long consonant doubles the first letter only (sz → ssz), but is written in
+// full on both sides of a hyphen (asszony → asz-szony).
+var hungarianDigraphs = []string{"dzs", "cs", "dz", "gy", "ly", "ny", "sz", "ty", "zs"}
+
+// HungarianLongConsonants hyphenates long Hungarian digraph consonants by
+// writing the digraph on both sides of the hyphen (asszony → asz-szony).
+func HungarianLongConsonants(left, right string) (Hyphenation, bool) {
+	for _, digraph := range hungarianDigraphs {
+		if !strings.HasPrefix(right, digraph) || !strings.HasSuffix(left, digraph[:1]) {
+			continue
+		}
+		return Hyphenation{
+			Left:    left[:len(left)-1],
+			Pre:     digraph + "-",
+			Post:    digraph,
+			NoBreak: digraph[:1] + digraph,
+			Right:   right[len(digraph):],
+		}, true
+	}
+	return Hyphenation{}, false
+}
+
+// hyphenatedKnots creates knots for the syllables of a hyphenated word, with
+// discretionaries between syllables. Hyphenation points are subject to
+// spelling changes, if change is not nil.
+func (pipeline *TypesettingPipeline) hyphenatedKnots(syllables []string, change SpellingChange) []Knot {
+	knots := make([]Knot, 0, 2*len(syllables))
+	left := syllables[0]
+	for _, right := range syllables[1:] {
+		if change != nil {
+			if h, ok := change(left, right); ok {
+				if h.Left != "" {
+					knots = append(knots, pipeline.newTextBox(h.Left))
+				}
+				knots = append(knots, pipeline.newSpellingDiscretionary(h.Pre, h.Post, h.NoBreak))
+				left = h.Right
+				continue
+			}
+		}
+		knots = append(knots, pipeline.newTextBox(left), pipeline.newDiscretionary())
+		left = right
+	}
+	if left != "" {
+		knots = append(knots, pipeline.newTextBox(left))
+	}
+	return knots
+}
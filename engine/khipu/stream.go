@@ -0,0 +1,98 @@
+package khipu
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+
+	params "github.com/npillmayer/gotype/core/parameters"
+)
+
+// Paragraph is a khipu for a single paragraph of a text, as produced by
+// EncodeParagraphs.
+type Paragraph struct {
+	Number int    // sequence number of the paragraph, starting at 1
+	Khipu  *Khipu // the encoded paragraph, nil in case of an error
+	Err    error  // error reading the input text
+}
+
+// maxLineLength is the maximum length of an input line in bytes.
+const maxLineLength = 1024 * 1024
+
+// EncodeParagraphs transforms an input text into khipus, one per paragraph.
+// Paragraphs are separated by blank lines. Within a paragraph, line ends are
+// treated as spaces.
+//
+// Encoding runs concurrently and khipus are delivered over a channel, holding
+// up to buflen paragraphs. Encoding will block while the channel is full, i.e.
+// it will not run ahead of the client by more than buflen paragraphs.
+// The channel is closed after the last paragraph or after an error, which will be
+// delivered as a Paragraph with Err set.
+//
+// Clients which are not interested in further paragraphs must call the returned
+// stop function to terminate encoding. Calling stop more than once is fine.
+//
+// The pipeline is used exclusively by the encoder until the channel is closed.
+// The typesetting registers must not be changed during encoding.
+func EncodeParagraphs(text io.Reader, pipeline *TypesettingPipeline,
+	regs *params.TypesettingRegisters, buflen int) (<-chan Paragraph, func()) {
+	//
+	if regs == nil {
+		regs = params.NewTypesettingRegisters()
+	}
+	if buflen < 0 {
+		buflen = 0
+	}
+	pipeline = PrepareTypesettingPipeline(strings.NewReader(""), pipeline)
+	paragraphs := make(chan Paragraph, buflen)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(done) })
+	}
+	go func() {
+		defer close(paragraphs)
+		send := func(p Paragraph) bool {
+			select {
+			case paragraphs <- p:
+				return true
+			case <-done:
+				CT().Debugf("paragraph encoding stopped by client")
+				return false
+			}
+		}
+		var par strings.Builder
+		n := 0
+		encode := func() bool { // encode collected lines, if any
+			if par.Len() == 0 {
+				return true
+			}
+			n++
+			kh := KnotEncode(strings.NewReader(par.String()), pipeline, regs)
+			par.Reset()
+			return send(Paragraph{Number: n, Khipu: kh})
+		}
+		scanner := bufio.NewScanner(text)
+		scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" { // blank line ends a paragraph
+				if !encode() {
+					return
+				}
+				continue
+			}
+			if par.Len() > 0 {
+				par.WriteByte(' ')
+			}
+			par.WriteString(line)
+		}
+		if err := scanner.Err(); err != nil {
+			send(Paragraph{Number: n + 1, Err: err})
+			return
+		}
+		encode()
+	}()
+	return paragraphs, stop
+}
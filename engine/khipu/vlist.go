@@ -0,0 +1,165 @@
+package khipu
+
+import (
+	"fmt"
+
+	"github.com/npillmayer/gotype/core/dimen"
+	params "github.com/npillmayer/gotype/core/parameters"
+)
+
+// A vertical list is a khipu of type VList. Its knots are stacked from top
+// to bottom, and the widths of its knots (see interface Knot) are interpreted
+// as vertical extents: glue is vertical glue, a kern is vertical space and
+// a line extends from the top of its height to the bottom of its depth.
+//
+// Vertical lists contain lines (packed by HPack), glue, kerns, penalties
+// and inserts. They are the input for page breakers.
+
+// NewVList creates an empty vertical list.
+func NewVList() *Khipu {
+	kh := NewKhipu()
+	kh.typ = VList
+	return kh
+}
+
+// IsVList returns true if a khipu is a vertical list.
+func (kh *Khipu) IsVList() bool {
+	return kh.typ == VList
+}
+
+// --- Lines -----------------------------------------------------------------
+
+// Type is part of interface Knot.
+func (l *Line) Type() KnotType {
+	return KTLine
+}
+
+// W is part of interface Knot. Returns the vertical extent (height plus depth)
+// of the line.
+func (l *Line) W() dimen.Dimen {
+	return l.Height + l.Depth
+}
+
+// MinW is part of interface Knot. Lines do not shrink vertically.
+func (l *Line) MinW() dimen.Dimen {
+	return l.W()
+}
+
+// MaxW is part of interface Knot. Lines do not stretch vertically.
+func (l *Line) MaxW() dimen.Dimen {
+	return l.W()
+}
+
+// IsDiscardable is part of interface Knot. Lines are not discardable.
+func (l *Line) IsDiscardable() bool {
+	return false
+}
+
+var _ Knot = &Line{}
+
+// --- Inserts ---------------------------------------------------------------
+
+// An Insert holds material which will be moved to a special place of the
+// page it occurs on, e.g., a footnote. Inserts reduce the space on the page for
+// other material by their height.
+type Insert struct {
+	Class  int         // class of insert, e.g. footnotes or margin notes
+	Height dimen.Dimen // height the insert will take from the page
+	Lines  []*Line     // content of the insert
+}
+
+// Type is part of interface Knot.
+func (ins Insert) Type() KnotType {
+	return KTInsert
+}
+
+func (ins Insert) String() string {
+	return fmt.Sprintf("⎀%d(%.2f)", ins.Class, ins.Height.Points())
+}
+
+// W is part of interface Knot. Returns the height of the insert.
+func (ins Insert) W() dimen.Dimen {
+	return ins.Height
+}
+
+// MinW is part of interface Knot. Inserts do not shrink.
+func (ins Insert) MinW() dimen.Dimen {
+	return ins.Height
+}
+
+// MaxW is part of interface Knot. Inserts do not stretch.
+func (ins Insert) MaxW() dimen.Dimen {
+	return ins.Height
+}
+
+// IsDiscardable is part of interface Knot. Inserts are not discardable.
+func (ins Insert) IsDiscardable() bool {
+	return false
+}
+
+// --- Stacking lines --------------------------------------------------------
+
+// AppendLines appends the lines of a paragraph to a vertical list.
+//
+// Interline glue is inserted in front of every line following a previous line
+// in the list (which may be part of a previous paragraph): the glue is
+// calculated to make the distance between the baselines equal to P_BASELINESKIP.
+// If this would bring the lines closer than P_LINESKIPLIMIT, glue of
+// P_LINESKIP is used instead.
+//
+// Between the lines of the paragraph, a penalty is inserted, consisting of
+// P_CLUBPENALTY after the first line and P_WIDOWPENALTY before the last one.
+// Zero penalties are omitted, as glue is a legal breakpoint as well.
+func (kh *Khipu) AppendLines(lines []*Line, regs *params.TypesettingRegisters) *Khipu {
+	if regs == nil {
+		regs = params.NewTypesettingRegisters()
+	}
+	prevdepth, hasPrev := kh.prevDepth()
+	for i, line := range lines {
+		if i > 0 {
+			var p int
+			if i == 1 {
+				p += regs.N(params.P_CLUBPENALTY)
+			}
+			if i == len(lines)-1 {
+				p += regs.N(params.P_WIDOWPENALTY)
+			}
+			if p != 0 {
+				kh.AppendKnot(Penalty(p))
+			}
+		}
+		if hasPrev {
+			kh.AppendKnot(interlineGlue(prevdepth, line.Height, regs))
+		}
+		kh.AppendKnot(line)
+		prevdepth, hasPrev = line.Depth, true
+	}
+	return kh
+}
+
+// interlineGlue calculates the glue between two lines, similar to TeX.
+func interlineGlue(prevdepth, height dimen.Dimen, regs *params.TypesettingRegisters) Glue {
+	d := regs.D(params.P_BASELINESKIP) - prevdepth - height
+	if d < regs.D(params.P_LINESKIPLIMIT) {
+		return NewGlue(regs.D(params.P_LINESKIP), 0, 0)
+	}
+	return NewGlue(d, 0, 0)
+}
+
+// prevDepth finds the depth of the last line in a vertical list. Returns false
+// if there is no line in the list or if the last line is followed by
+// non-discardable material other than inserts.
+func (kh *Khipu) prevDepth() (dimen.Dimen, bool) {
+	for i := len(kh.knots) - 1; i >= 0; i-- {
+		switch k := kh.knots[i].(type) {
+		case *Line:
+			return k.Depth, true
+		case Insert:
+			continue
+		}
+		if !kh.knots[i].IsDiscardable() {
+			break
+		}
+	}
+	return 0, false
+}
@@ -1,5 +1,6 @@
 /*
-CGo wrapper for the Harfbuzz text shaping library.
+Package harfbuzz is a CGo wrapper for the Harfbuzz text shaping library.
+It implements textshaping.TextShaper. Using it requires cgo and libharfbuzz.
 
 ----------------------------------------------------------------------
 
@@ -38,12 +39,13 @@ OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 ----------------------------------------------------------------------
 */
-package textshaping
+package harfbuzz
 
 import (
 	"fmt"
 
 	"github.com/npillmayer/gotype/core/font"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
 )
 
 // Harfbuzz is the de-facto standard for text shaping.
@@ -60,9 +62,9 @@ import (
 // The downside of this is the need to free() memory whenever we
 // hand a Harfbuzz-shaper to GC.
 type Harfbuzz struct {
-	buffer    uintptr       // central data structure for Harfbuzz
-	direction TextDirection // L-to-R, R-to-L, T-to-B
-	script    ScriptID      // i.e., Latin, Arabic, Korean, ...
+	buffer    uintptr                   // central data structure for Harfbuzz
+	direction textshaping.TextDirection // L-to-R, R-to-L, T-to-B
+	script    textshaping.ScriptID      // i.e., Latin, Arabic, Korean, ...
 }
 
 // Create a new Harfbuzz text shaper, fully initialized.
@@ -70,9 +72,9 @@ type Harfbuzz struct {
 func NewHarfbuzz() *Harfbuzz {
 	hb := &Harfbuzz{}
 	hb.buffer = allocHBBuffer()
-	hb.direction = LeftToRight
+	hb.direction = textshaping.LeftToRight
 	setHBBufferDirection(hb.buffer, hb.direction)
-	hb.script = Latin
+	hb.script = textshaping.Latin
 	setHBBufferScript(hb.buffer, hb.script)
 	return hb
 }
@@ -98,12 +100,12 @@ func (hb *Harfbuzz) findFont(typecase *font.TypeCase) uintptr {
 }
 
 // Implement TextShaper interface.
-func (hb *Harfbuzz) SetScript(scr ScriptID) {
+func (hb *Harfbuzz) SetScript(scr textshaping.ScriptID) {
 	setHBBufferScript(hb.buffer, scr)
 }
 
 // Implement TextShaper interface.
-func (hb *Harfbuzz) SetDirection(dir TextDirection) {
+func (hb *Harfbuzz) SetDirection(dir textshaping.TextDirection) {
 	setHBBufferDirection(hb.buffer, dir)
 }
 
@@ -116,7 +118,7 @@ func (hb *Harfbuzz) SetLanguage() {
 //
 // This is where all the heavy lifting is done. We input a font and a
 // string of Unicode code-points, and receive a list of glyphs.
-func (hb *Harfbuzz) Shape(text string, typecase *font.TypeCase) GlyphSequence {
+func (hb *Harfbuzz) Shape(text string, typecase *font.TypeCase) textshaping.GlyphSequence {
 	var hbfont uintptr
 	hbfont = hb.findFont(typecase)
 	if hbfont == 0 {
@@ -128,7 +130,7 @@ func (hb *Harfbuzz) Shape(text string, typecase *font.TypeCase) GlyphSequence {
 	return seq
 }
 
-func (hb *Harfbuzz) GlyphSequenceString(typecase *font.TypeCase, seq GlyphSequence) string {
+func (hb *Harfbuzz) GlyphSequenceString(typecase *font.TypeCase, seq textshaping.GlyphSequence) string {
 	var hbfont uintptr
 	hbfont = hb.findFont(typecase)
 	if hbfont == 0 {
@@ -1,4 +1,4 @@
-package textshaping
+package harfbuzz
 
 //#cgo CPPFLAGS: -I/usr/local/include/harfbuzz
 //#cgo LDFLAGS: -L/usr/local/lib -lharfbuzz
@@ -20,6 +20,7 @@ import (
 	"unsafe"
 
 	"github.com/npillmayer/gotype/core/font"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
 )
 
 /*
@@ -74,28 +75,28 @@ func freeHBBuffer(buf uintptr) {
 }
 
 // Helper: convert a Textdirection enum into a flag suited for Harfbuzz
-func dir2hbdir(textdir TextDirection) int32 {
+func dir2hbdir(textdir textshaping.TextDirection) int32 {
 	switch textdir {
-	case LeftToRight:
+	case textshaping.LeftToRight:
 		return 4
-	case RightToLeft:
+	case textshaping.RightToLeft:
 		return 5
-	case TopToBottom:
+	case textshaping.TopToBottom:
 		return 6
-	case BottomToTop:
+	case textshaping.BottomToTop:
 		return 7
 	}
 	return 4
 }
 
 // Set the text direction flag for a Harfbuzz buffer.
-func setHBBufferDirection(hbbuf uintptr, dir TextDirection) {
+func setHBBufferDirection(hbbuf uintptr, dir textshaping.TextDirection) {
 	ptr := (*C.struct_hb_buffer_t)(unsafe.Pointer(hbbuf))
 	C.hb_buffer_set_direction(ptr, C.hb_direction_t(dir2hbdir(dir)))
 }
 
 // Set the script info for a Harfbuzz buffer.
-func setHBBufferScript(hbbuf uintptr, script ScriptID) {
+func setHBBufferScript(hbbuf uintptr, script textshaping.ScriptID) {
 	ptr := (*C.struct_hb_buffer_t)(unsafe.Pointer(hbbuf))
 	C.hb_buffer_set_script(ptr, C.hb_script_t(script))
 }
@@ -151,7 +152,7 @@ type hbGlyphSequence struct {
 	pos    *C.hb_glyph_position_t
 }
 
-// Implement the GlyphSequence interface
+// Implement the textshaping.GlyphSequence interface
 func (seq *hbGlyphSequence) GlyphCount() int {
 	return seq.length
 }
@@ -165,8 +166,8 @@ type hbGlyphInfo struct {
 	y        float64
 }
 
-// Implement the GlyphSequence interface
-func (seq *hbGlyphSequence) GetGlyphInfoAt(i int) GlyphInfo {
+// Implement the textshaping.GlyphSequence interface
+func (seq *hbGlyphSequence) GetGlyphInfoAt(i int) textshaping.GlyphInfo {
 	gi := &hbGlyphInfo{}
 	info := C.get_glyph_info_at(seq.info, C.int(i))
 	pos := C.get_glyph_position_at(seq.pos, C.int(i))
@@ -179,32 +180,32 @@ func (seq *hbGlyphSequence) GetGlyphInfoAt(i int) GlyphInfo {
 	return gi
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) Glyph() rune {
 	return gi.glyph
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) Cluster() int {
 	return gi.cluster
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) XAdvance() float64 {
 	return gi.xadvance
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) YAdvance() float64 {
 	return gi.yadvance
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) XPosition() float64 {
 	return gi.x
 }
 
-// Implement the GlyphInfo interface
+// Implement the textshaping.GlyphInfo interface
 func (gi *hbGlyphInfo) YPosition() float64 {
 	return gi.y
 }
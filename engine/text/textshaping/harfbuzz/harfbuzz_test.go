@@ -1,10 +1,11 @@
-package textshaping
+package harfbuzz
 
 import (
 	"fmt"
 	"testing"
 
 	"github.com/npillmayer/gotype/core/font"
+	"github.com/npillmayer/gotype/engine/text/textshaping"
 	"github.com/npillmayer/gotype/gtlocate"
 )
 
@@ -44,7 +45,7 @@ func TestHarfbuzzShape(t *testing.T) {
 }
 
 func TestHarfbuzzShapeResult(t *testing.T) {
-	var seq GlyphSequence
+	var seq textshaping.GlyphSequence
 	fontpath := gtlocate.FileResource("GentiumPlus-R.ttf", "font")
 	if f, err := font.LoadOpenTypeFont(fontpath); err == nil {
 		if tc, err2 := f.PrepareCase(12.0); err2 == nil {
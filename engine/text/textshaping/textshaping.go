@@ -5,6 +5,9 @@ Unicode characters.
 To understand what a text shaper does, please have a look at
 http://www.manpagez.com/html/harfbuzz/harfbuzz-/what-is-harfbuzz.php
 
+This package defines the interfaces for text shapers only. A text shaper
+using Harfbuzz is implemented in sub-package harfbuzz, which needs cgo.
+
 
 BSD License
 
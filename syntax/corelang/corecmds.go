@@ -45,6 +45,7 @@ import (
 	"github.com/antlr/antlr4/runtime/Go/antlr"
 
 	"github.com/npillmayer/gotype/core/arithmetic"
+	"github.com/npillmayer/gotype/core/dimen"
 	"github.com/npillmayer/gotype/syntax/runtime"
 	"github.com/npillmayer/gotype/syntax/variables"
 	"github.com/npillmayer/gotype/syntax/variables/varparse"
@@ -569,14 +570,11 @@ func Showvariable(rt *runtime.Runtime, tag string) string {
 
 // === Utilities =============================================================
 
-/*
-TODO complete this.
-Return scaled points for high level units (cm, mm, pt, in, ...)
-*/
+// Unit2numeric returns the value of a unit (cm, mm, pt, in, ...) in
+// big points, the default unit of MetaPost.
 func Unit2numeric(u string) dec.Decimal {
-	switch u {
-	case "in":
-		return dec.NewFromFloat(0.01388888)
+	if d, ok := dimen.Unit(u); ok {
+		return dec.New(int64(d), 0).Div(dec.New(int64(dimen.BP), 0))
 	}
 	return arithmetic.ConstOne
 }